@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type asxPlayList struct {
+	XMLName xml.Name   `xml:"asx"`
+	Version string     `xml:"version,attr"`
+	Title   string     `xml:"title"`
+	Entries []asxEntry `xml:"entry"`
+}
+
+type asxEntry struct {
+	Title     string    `xml:"title"`
+	Ref       asxValue  `xml:"ref"`
+	StartTime *asxValue `xml:"starttime"`
+	Duration  *asxValue `xml:"duration"`
+}
+
+type asxValue struct {
+	Href  string `xml:"href,attr,omitempty"`
+	Value string `xml:"value,attr,omitempty"`
+}
+
+// asxClock formats seconds as the hh:mm:ss clock value ASX expects
+func asxClock(seconds uint16) string {
+	d := time.Duration(seconds) * time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+type asxExporter struct{}
+
+func (asxExporter) Name() string { return FormatAsx }
+
+func (asxExporter) Extension() string { return ".asx" }
+
+// Export writes an ASX 3.0 playlist. Start and stop times map to the start
+// time and play duration of every entry; audio can't be turned off in ASX.
+func (asxExporter) Export(content []MediaItem, options ExportOptions, w io.Writer) error {
+	paths, err := exportPaths(content, options.Base)
+	if err != nil {
+		return err
+	}
+	play := options.PlayOptions
+	playList := asxPlayList{Version: "3.0", Title: exportTitle}
+	for i, item := range content {
+		entry := asxEntry{Title: item.Name, Ref: asxValue{Href: paths[i]}}
+		if play.StartTime > 0 {
+			entry.StartTime = &asxValue{Value: asxClock(play.StartTime)}
+		}
+		if play.StopTime > 0 {
+			entry.Duration = &asxValue{Value: asxClock(play.StopTime - play.StartTime)}
+		}
+		playList.Entries = append(playList.Entries, entry)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+	err = encoder.Encode(playList)
+	if err != nil {
+		return fmt.Errorf("Error writing asx playlist: %w\n", err)
+	}
+	_, err = io.WriteString(w, "\n")
+	if err != nil {
+		return fmt.Errorf("Error writing asx playlist: %w\n", err)
+	}
+	return nil
+}
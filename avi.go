@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// RIFF chunks are [fourcc][size uint32 LE][data], padded to an even size.
+// LIST chunks carry an extra fourcc list type before their sub-chunks.
+// See https://learn.microsoft.com/en-us/windows/win32/directshow/avi-riff-file-reference
+const (
+	riffHeaderSize  = 12
+	chunkHeaderSize = 8
+	avihSize        = 56
+)
+
+type riffChunk struct {
+	id        string
+	listType  string
+	dataStart int64
+	size      int64
+}
+
+type riffReader struct {
+	r    io.ReaderAt
+	size int64
+}
+
+func (rr riffReader) readChunk(off int64) (riffChunk, error) {
+	buf := make([]byte, chunkHeaderSize+4)
+	n, err := rr.r.ReadAt(buf, off)
+	if n < chunkHeaderSize {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return riffChunk{}, err
+	}
+	chunk := riffChunk{
+		id:        string(buf[0:4]),
+		size:      int64(binary.LittleEndian.Uint32(buf[4:8])),
+		dataStart: off + chunkHeaderSize,
+	}
+	if chunk.id == "LIST" && n == len(buf) {
+		chunk.listType = string(buf[8:12])
+	}
+	return chunk, nil
+}
+
+// chunks lists the chunks between start and end, stopping at the first unreadable one.
+func (rr riffReader) chunks(start, end int64) []riffChunk {
+	var chunks []riffChunk
+	for off := start; off+chunkHeaderSize <= end; {
+		chunk, err := rr.readChunk(off)
+		if err != nil {
+			break
+		}
+		chunks = append(chunks, chunk)
+		off = chunk.dataStart + chunk.size + chunk.size%2
+	}
+	return chunks
+}
+
+func (rr riffReader) readUint32s(off int64, n int) ([]uint32, error) {
+	buf := make([]byte, 4*n)
+	if _, err := rr.r.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	values := make([]uint32, n)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint32(buf[4*i : 4*i+4])
+	}
+	return values, nil
+}
+
+// dmlhFrames returns the total frame count stored by OpenDML (files over 1 GB)
+// in hdrl/odml/dmlh, as avih only counts the frames of the first RIFF chunk.
+func (rr riffReader) dmlhFrames(hdrl riffChunk) uint32 {
+	for _, chunk := range rr.chunks(hdrl.dataStart+4, hdrl.dataStart+hdrl.size) {
+		if chunk.id != "LIST" || chunk.listType != "odml" {
+			continue
+		}
+		for _, sub := range rr.chunks(chunk.dataStart+4, chunk.dataStart+chunk.size) {
+			if sub.id == "dmlh" && sub.size >= 4 {
+				values, err := rr.readUint32s(sub.dataStart, 1)
+				if err == nil {
+					return values[0]
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func (rr riffReader) getDuration() (float64, error) {
+	header := make([]byte, riffHeaderSize)
+	if _, err := rr.r.ReadAt(header, 0); err != nil {
+		return 0, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "AVI " {
+		return 0, fmt.Errorf("RIFF AVI header not found. Is this avi?")
+	}
+	riffEnd := chunkHeaderSize + int64(binary.LittleEndian.Uint32(header[4:8]))
+	if riffEnd > rr.size {
+		riffEnd = rr.size
+	}
+	for _, hdrl := range rr.chunks(riffHeaderSize, riffEnd) {
+		if hdrl.id != "LIST" || hdrl.listType != "hdrl" {
+			continue
+		}
+		for _, chunk := range rr.chunks(hdrl.dataStart+4, hdrl.dataStart+hdrl.size) {
+			if chunk.id != "avih" || chunk.size < avihSize {
+				continue
+			}
+			// avih starts with dwMicroSecPerFrame, dwMaxBytesPerSec,
+			// dwPaddingGranularity, dwFlags and dwTotalFrames
+			values, err := rr.readUint32s(chunk.dataStart, 5)
+			if err != nil {
+				return 0, err
+			}
+			microSecPerFrame, totalFrames := values[0], values[4]
+			if frames := rr.dmlhFrames(hdrl); frames > totalFrames {
+				totalFrames = frames
+			}
+			return float64(microSecPerFrame) * float64(totalFrames) / 1e6, nil
+		}
+	}
+	return 0, fmt.Errorf("avih chunk not found")
+}
+
+func getAviDuration(r io.ReaderAt, size int64) (float64, error) {
+	reader := riffReader{r: r, size: size}
+	return reader.getDuration()
+}
+
+type aviProber struct{}
+
+func (aviProber) Name() string { return "avi" }
+
+func (aviProber) Extensions() []string { return []string{".avi"} }
+
+func (aviProber) Match(header []byte) bool {
+	return len(header) >= riffHeaderSize && string(header[0:4]) == "RIFF" && string(header[8:12]) == "AVI "
+}
+
+func (aviProber) Probe(r io.ReaderAt, size int64) (MediaInfo, error) {
+	duration, err := getAviDuration(r, size)
+	return MediaInfo{Duration: duration}, err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestGetAviDuration(t *testing.T) {
+	data := mocks.CreateAviData(1500, 0)
+	duration, err := getAviDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should be 60 seconds", duration, 60)
+}
+
+func TestGetAviDurationOpenDML(t *testing.T) {
+	data := mocks.CreateAviData(1500, 45000)
+	duration, err := getAviDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should use dmlh frame count", duration, 1800)
+}
+
+func TestGetAviDurationNotRiff(t *testing.T) {
+	data := mocks.CreateData(60)
+	_, err := getAviDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should raise error for mp4 data", err, true)
+}
+
+func TestGetAviDurationMissingAvih(t *testing.T) {
+	data := mocks.CreateAviData(1500, 0)
+	data = data[:30]
+	_, err := getAviDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should raise error for truncated header", err, true)
+}
+
+func TestGetDurationAvi(t *testing.T) {
+	fn := "clip.avi"
+	f := fstest.MapFS{
+		fn: {
+			Data:    mocks.CreateAviData(250, 0),
+			Mode:    0755,
+			ModTime: time.Now(),
+		},
+	}
+	duration, err := getDuration(f, fn)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should be 10 seconds", duration, 10)
+}
+
+func TestCollectMediaContentAviBucket(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       1000,
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+	}
+	fsys := fstest.MapFS{
+		"clip.avi": {
+			Data:    mocks.CreateAviData(5000, 0),
+			Mode:    0755,
+			ModTime: modTime,
+		},
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should select avi file", items[0].Duration, 200)
+	assert.Equal(t, "Should allocate to 180-240 bucket", summary.dBucket.counts[5], 1)
+}
@@ -1,18 +1,56 @@
 package main
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"image/color"
 	"log"
+	"math"
+	"math/rand"
+	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	vlc "github.com/adrg/libvlc-go/v3"
 )
 
-var mediaExtensions = []string{".mp4", ".mkv", ".avi", ".flv", ".mpeg"}
+// names of the filters counted in the summary
+const (
+	filterIncludeFolder  = "include_folder"
+	filterIncludePattern = "include_pattern"
+	filterExcludePattern = "exclude_pattern"
+	filterDate           = "date"
+	filterNoAudio        = "no_audio"
+)
+
+const (
+	DateSourceMtime          = "mtime"
+	DateSourceMvhdCreation   = "mvhd-creation"
+	DateSourceFileName       = "filename-pattern"
+	DateSourceFirstAvailable = "first-available"
+)
+
+const (
+	ErrorPolicyAbort         = "abort"
+	ErrorPolicySkip          = "skip"
+	ErrorPolicySkipAndReport = "skip-and-report"
+)
+
+// maxTargetDuration bounds target_duration plus tolerance, as the duration
+// selection needs memory and time in proportion to it
+const maxTargetDuration = 24 * 60 * 60
+
+const (
+	OrderShuffle    = "shuffle"
+	OrderRoundRobin = "round-robin"
+	OrderWeighted   = "weighted"
+	OrderSpread     = "spread"
+)
 
 const (
 	ExtensionApplication = "http://www.videolan.org/vlc/playlist/0"
@@ -64,21 +102,35 @@ type TrackList struct {
 }
 
 type PlayList struct {
-	XMLName  xml.Name  `xml:"playlist"`
-	Xmlns    string    `xml:"xmlns,attr"`
-	XmlnsVlc string    `xml:"xmlns:vlc,attr"`
-	Version  string    `xml:"version,attr"`
-	Title    string    `xml:"title"`
-	Tl       TrackList `xml:"trackList"`
+	XMLName    xml.Name  `xml:"playlist"`
+	Xmlns      string    `xml:"xmlns,attr"`
+	XmlnsVlc   string    `xml:"xmlns:vlc,attr"`
+	Version    string    `xml:"version,attr"`
+	Title      string    `xml:"title"`
+	Annotation string    `xml:"annotation,omitempty"`
+	Tl         TrackList `xml:"trackList"`
+}
+
+// annotationPrefix starts the annotation of every playlist written by playmix
+const annotationPrefix = "Generated by playmix"
+
+// annotateSeed records the seed the playlist was generated with
+func (p *PlayList) annotateSeed(seed int64) {
+	p.Annotation = fmt.Sprintf("%s with seed %d", annotationPrefix, seed)
 }
 
 type FileOptions struct {
 	MediaPath         string            `json:"media_path"`
 	FileName          string            `json:"file_name"`
+	Format            string            `json:"format"`
+	RelativePaths     bool              `json:"relative_paths"`
+	ExtraOutputs      []string          `json:"extra_outputs"`
 	Marquee           Marquee           `json:"marquee"`
 	PlayOptions       PlayOptions       `json:"play_options"`
 	RandomizerOptions RandomizerOptions `json:"randomizer_options"`
 	FilterOptions     FilterOptions     `json:"filter_options"`
+	ScanOptions       ScanOptions       `json:"scan_options"`
+	SummaryOptions    SummaryOptions    `json:"summary_options"`
 }
 
 func (f *FileOptions) validatePath() error {
@@ -160,18 +212,229 @@ func (p PlayOptions) StringifyStopTime() string {
 	return "stop-time=" + strconv.Itoa(int(p.StopTime))
 }
 
+// trackOptions lists the VLC options applied to every track
+func (p PlayOptions) trackOptions() []string {
+	var options []string
+	if !p.Audio {
+		options = append(options, p.StringifyAudio())
+	}
+	if p.StartTime > 0 {
+		options = append(options, p.StringifyStartTime())
+	}
+	if p.StopTime > 0 {
+		options = append(options, p.StringifyStopTime())
+	}
+	return options
+}
+
 type RandomizerOptions struct {
-	Ratio      uint8  `json:"ratio,omitempty"`
-	Stabilizer uint32 `json:"stabilizer,omitempty"`
+	Ratio        uint8            `json:"ratio,omitempty"`
+	Stabilizer   uint32           `json:"stabilizer,omitempty"`
+	FolderRatios map[string]uint8 `json:"folder_ratios,omitempty"`
+	Order        string           `json:"order,omitempty"`
+	MinGap       int              `json:"min_gap,omitempty"`
+	Seed         int64            `json:"seed,omitempty"`
+	// TargetDuration and Tolerance are in seconds
+	TargetDuration int `json:"target_duration,omitempty"`
+	Tolerance      int `json:"tolerance,omitempty"`
+	Count          int `json:"count,omitempty"`
+	// BucketQuota maps duration bands to their share of the playlist
+	BucketQuota map[string]Percent `json:"bucket_quota,omitempty"`
+	bands       []durationBand
+}
+
+// Percent accepts both 20 and "20%" in the options file
+type Percent float64
+
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	var value float64
+	if err := json.Unmarshal(data, &value); err == nil {
+		*p = Percent(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("Percent should be a number or a string like \"20%%\", got %s", data)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(text, "%")), 64)
+	if err != nil {
+		return fmt.Errorf("Percent should be a number or a string like \"20%%\", got %s", data)
+	}
+	*p = Percent(value)
+	return nil
+}
+
+// durationBand is a duration range in seconds, from included and to excluded
+type durationBand struct {
+	label string
+	from  float64
+	to    float64
+	share float64
+}
+
+func (b durationBand) contains(duration float64) bool {
+	return duration >= b.from && duration < b.to
+}
+
+// parseBand reads "30-180", "<30" and ">180" band labels
+func parseBand(label string) (durationBand, error) {
+	band := durationBand{label: label, from: 0, to: math.Inf(1)}
+	var err error
+	switch {
+	case strings.HasPrefix(label, "<"):
+		band.to, err = strconv.ParseFloat(label[1:], 64)
+	case strings.HasPrefix(label, ">"):
+		band.from, err = strconv.ParseFloat(label[1:], 64)
+	default:
+		from, to, found := strings.Cut(label, "-")
+		if !found {
+			return band, fmt.Errorf("Bucket quota band should look like 30-180, <30 or >180, got %s\n", label)
+		}
+		band.from, err = strconv.ParseFloat(from, 64)
+		if err == nil {
+			band.to, err = strconv.ParseFloat(to, 64)
+		}
+	}
+	if err != nil || band.from >= band.to {
+		return band, fmt.Errorf("Invalid bucket quota band %s\n", label)
+	}
+	return band, nil
+}
+
+// parseBucketQuota validates the quota bands, which should not overlap and
+// whose shares should add up to 100
+func (r *RandomizerOptions) parseBucketQuota() error {
+	if len(r.BucketQuota) == 0 {
+		return nil
+	}
+	if r.TargetDuration > 0 {
+		return fmt.Errorf("Bucket quota and target duration are mutually exclusive")
+	}
+	var bands []durationBand
+	var total float64
+	for label, share := range r.BucketQuota {
+		band, err := parseBand(label)
+		if err != nil {
+			return err
+		}
+		if share < 0 {
+			return fmt.Errorf("Share of bucket quota band %s should not be negative, got %.2f\n", label, share)
+		}
+		band.share = float64(share)
+		total += band.share
+		bands = append(bands, band)
+	}
+	sort.Slice(bands, func(i, j int) bool {
+		return bands[i].from < bands[j].from
+	})
+	for i := 1; i < len(bands); i++ {
+		if bands[i].from < bands[i-1].to {
+			return fmt.Errorf("Bucket quota bands %s and %s overlap\n", bands[i-1].label, bands[i].label)
+		}
+	}
+	if math.Abs(total-100) > 0.01 {
+		return fmt.Errorf("Bucket quota shares should add up to 100, got %.2f\n", total)
+	}
+	r.bands = bands
+	return nil
+}
+
+// validateTargetDuration runs before the ratio is defaulted, as a set ratio
+// and a target duration would both decide how many files are selected
+func (r RandomizerOptions) validateTargetDuration() error {
+	if r.TargetDuration < 0 || r.Tolerance < 0 {
+		return fmt.Errorf("Target duration and tolerance should not be negative, got %d and %d\n", r.TargetDuration, r.Tolerance)
+	}
+	if r.TargetDuration+r.Tolerance > maxTargetDuration {
+		return fmt.Errorf("Target duration and tolerance should not exceed %d seconds together, got %d and %d\n", maxTargetDuration, r.TargetDuration, r.Tolerance)
+	}
+	if r.TargetDuration > 0 && r.Ratio != 0 {
+		return fmt.Errorf("Ratio and target duration are mutually exclusive")
+	}
+	return nil
+}
+
+// validateCount runs before the ratio is defaulted, like validateTargetDuration
+func (r RandomizerOptions) validateCount() error {
+	if r.Count < 0 {
+		return fmt.Errorf("Count should not be negative, got %d\n", r.Count)
+	}
+	if r.Count > 0 && (r.Ratio != 0 || r.TargetDuration != 0) {
+		return fmt.Errorf("Count is mutually exclusive with ratio and target duration")
+	}
+	return nil
+}
+
+// setSeed picks the seed: the flag wins over the options file, and a new
+// seed is drawn from the clock when neither sets one
+func (r *RandomizerOptions) setSeed(flagSeed int64) {
+	if flagSeed != 0 {
+		r.Seed = flagSeed
+	}
+	if r.Seed == 0 {
+		r.Seed = time.Now().UnixNano()
+	}
+}
+
+func (r RandomizerOptions) newRand() *rand.Rand {
+	return rand.New(rand.NewSource(r.Seed))
+}
+
+func (r RandomizerOptions) validateOrder() error {
+	if r.MinGap < 0 {
+		return fmt.Errorf("Minimum gap should not be negative, got %d\n", r.MinGap)
+	}
+	switch r.Order {
+	case "", OrderShuffle, OrderRoundRobin, OrderWeighted, OrderSpread:
+		return nil
+	}
+	return fmt.Errorf("Order should be one of %s, %s, %s, %s, got %s\n", OrderShuffle, OrderRoundRobin, OrderWeighted, OrderSpread, r.Order)
+}
+
+// minGap defaults to one item between two items of the same folder
+func (r RandomizerOptions) minGap() int {
+	if r.MinGap == 0 {
+		return 1
+	}
+	return r.MinGap
 }
 
 func (r RandomizerOptions) validateRatio() error {
 	if r.Ratio < 0 || r.Ratio > 100 {
 		return fmt.Errorf("Ratio should be between 0 and 100, got %d\n", r.Ratio)
 	}
+	for folder, ratio := range r.FolderRatios {
+		if ratio > 100 {
+			return fmt.Errorf("Ratio of folder %s should be between 0 and 100, got %d\n", folder, ratio)
+		}
+	}
 	return nil
 }
 
+// normalizeFolderRatios turns the folder keys into clean slash separated relative paths
+func (r *RandomizerOptions) normalizeFolderRatios() {
+	if len(r.FolderRatios) == 0 {
+		return
+	}
+	ratios := make(map[string]uint8, len(r.FolderRatios))
+	for folder, ratio := range r.FolderRatios {
+		folder = strings.Trim(path.Clean(filepath.ToSlash(folder)), "/")
+		ratios[folder] = ratio
+	}
+	r.FolderRatios = ratios
+}
+
+// ratioFor returns the ratio of the nearest folder rule above the file at relPath
+// together with the rule folder, or the global ratio and "" when no rule applies.
+func (r RandomizerOptions) ratioFor(relPath string) (uint8, string) {
+	for dir := path.Dir(relPath); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if ratio, found := r.FolderRatios[dir]; found {
+			return ratio, dir
+		}
+	}
+	return r.Ratio, ""
+}
+
 // TODO: think about this - defaulting is confusing
 func (r *RandomizerOptions) setDefaultRatio() {
 	if r.Ratio == 0 {
@@ -180,8 +443,13 @@ func (r *RandomizerOptions) setDefaultRatio() {
 }
 
 type FilterOptions struct {
-	IncludeF []string `json:"include_folder"`
-	Skipf    []string `json:"skip_folder"`
+	IncludeF       []string `json:"include_folder"`
+	Skipf          []string `json:"skip_folder"`
+	IncludePattern []string `json:"include_pattern,omitempty"`
+	ExcludePattern []string `json:"exclude_pattern,omitempty"`
+	DateSource     string   `json:"date_source,omitempty"`
+	include        []pathPattern
+	exclude        []pathPattern
 }
 
 func (f *FilterOptions) validateFilterOptions() error {
@@ -190,3 +458,110 @@ func (f *FilterOptions) validateFilterOptions() error {
 	}
 	return nil
 }
+
+func (f *FilterOptions) compilePatterns() error {
+	var err error
+	f.include, err = compilePatterns(f.IncludePattern)
+	if err != nil {
+		return err
+	}
+	f.exclude, err = compilePatterns(f.ExcludePattern)
+	return err
+}
+
+// isPatternIncluded reports whether the file matches an include pattern,
+// any file does when none is set
+func (f FilterOptions) isPatternIncluded(relPath, name string) bool {
+	return len(f.include) == 0 || matchAny(f.include, relPath, name)
+}
+
+func (f FilterOptions) isPatternExcluded(relPath, name string) bool {
+	return matchAny(f.exclude, relPath, name)
+}
+
+func (f FilterOptions) validateDateSource() error {
+	switch f.DateSource {
+	case "", DateSourceMtime, DateSourceMvhdCreation, DateSourceFileName, DateSourceFirstAvailable:
+		return nil
+	}
+	return fmt.Errorf("Date source should be one of %s, %s, %s, %s, got %s\n",
+		DateSourceMtime, DateSourceMvhdCreation, DateSourceFileName, DateSourceFirstAvailable, f.DateSource)
+}
+
+// needsProbe reports whether the date is only known once the file is probed
+func (f FilterOptions) needsProbe() bool {
+	return f.DateSource == DateSourceMvhdCreation || f.DateSource == DateSourceFirstAvailable
+}
+
+// defaultBucketEdges are the duration bucket edges of the summary, in seconds
+var defaultBucketEdges = []float64{5, 10, 30, 60, 180, 240}
+
+// logBucketEdges roughly triple from one second to almost three hours
+var logBucketEdges = []float64{1, 3, 10, 30, 100, 300, 1000, 3000, 10000}
+
+type SummaryOptions struct {
+	DurationBuckets BucketEdges `json:"duration_buckets,omitempty"`
+}
+
+// BucketEdges accepts a list of increasing edges in seconds or "log"
+type BucketEdges []float64
+
+func (b *BucketEdges) UnmarshalJSON(data []byte) error {
+	var spacing string
+	if err := json.Unmarshal(data, &spacing); err == nil {
+		if spacing != "log" {
+			return fmt.Errorf("Duration buckets should be a list of edges or \"log\", got %s", spacing)
+		}
+		*b = logBucketEdges
+		return nil
+	}
+	var edges []float64
+	if err := json.Unmarshal(data, &edges); err != nil {
+		return fmt.Errorf("Duration buckets should be a list of edges or \"log\", got %s", data)
+	}
+	*b = edges
+	return nil
+}
+
+func (b BucketEdges) validate() error {
+	for i := 1; i < len(b); i++ {
+		if b[i] <= b[i-1] {
+			return fmt.Errorf("Duration bucket edges should be increasing, got %v\n", []float64(b))
+		}
+	}
+	return nil
+}
+
+type ScanOptions struct {
+	ErrorPolicy string `json:"error_policy,omitempty"`
+	Workers     int    `json:"workers,omitempty"`
+	IndexFile   string `json:"index_file,omitempty"`
+}
+
+func (s ScanOptions) validateErrorPolicy() error {
+	switch s.ErrorPolicy {
+	case "", ErrorPolicyAbort, ErrorPolicySkip, ErrorPolicySkipAndReport:
+		return nil
+	}
+	return fmt.Errorf("Error policy should be one of %s, %s, %s, got %s\n", ErrorPolicyAbort, ErrorPolicySkip, ErrorPolicySkipAndReport, s.ErrorPolicy)
+}
+
+// skipErrors reports whether unreadable files are skipped instead of aborting the scan
+func (s ScanOptions) skipErrors() bool {
+	return s.ErrorPolicy == ErrorPolicySkip || s.ErrorPolicy == ErrorPolicySkipAndReport
+}
+
+func (s ScanOptions) validateWorkers() error {
+	if s.Workers < 0 {
+		return fmt.Errorf("Workers should not be negative, got %d\n", s.Workers)
+	}
+	return nil
+}
+
+// workerCount defaults to one worker per CPU
+func (s ScanOptions) workerCount() int {
+	if s.Workers == 0 {
+		return runtime.NumCPU()
+	}
+	return s.Workers
+}
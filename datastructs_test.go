@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"playmix/internal/assert"
+	"runtime"
 	"testing"
 )
 
@@ -194,3 +196,177 @@ func TestValidateFilterOptions(t *testing.T) {
 	err = opts.validateFilterOptions()
 	assert.ErrorRaised(t, "Validate should return error", err, true)
 }
+
+func TestValidateErrorPolicy(t *testing.T) {
+	for _, policy := range []string{"", ErrorPolicyAbort, ErrorPolicySkip, ErrorPolicySkipAndReport} {
+		opts := ScanOptions{ErrorPolicy: policy}
+		err := opts.validateErrorPolicy()
+		assert.ErrorRaised(t, "Should accept "+policy, err, false)
+	}
+	opts := ScanOptions{ErrorPolicy: "ignore"}
+	err := opts.validateErrorPolicy()
+	assert.ErrorRaised(t, "Should reject unknown policy", err, true)
+}
+
+func TestSkipErrors(t *testing.T) {
+	assert.Equal(t, "Default should abort", ScanOptions{}.skipErrors(), false)
+	assert.Equal(t, "abort should abort", ScanOptions{ErrorPolicy: ErrorPolicyAbort}.skipErrors(), false)
+	assert.Equal(t, "skip should skip", ScanOptions{ErrorPolicy: ErrorPolicySkip}.skipErrors(), true)
+	assert.Equal(t, "skip-and-report should skip", ScanOptions{ErrorPolicy: ErrorPolicySkipAndReport}.skipErrors(), true)
+}
+
+func TestWorkerCount(t *testing.T) {
+	assert.Equal(t, "Should default to CPU count", ScanOptions{}.workerCount(), runtime.NumCPU())
+	assert.Equal(t, "Should use configured workers", ScanOptions{Workers: 3}.workerCount(), 3)
+	err := ScanOptions{Workers: -1}.validateWorkers()
+	assert.ErrorRaised(t, "Should reject negative workers", err, true)
+}
+
+func TestValidateDateSource(t *testing.T) {
+	for _, source := range []string{"", DateSourceMtime, DateSourceMvhdCreation, DateSourceFileName, DateSourceFirstAvailable} {
+		opts := FilterOptions{DateSource: source}
+		err := opts.validateDateSource()
+		assert.ErrorRaised(t, "Should accept "+source, err, false)
+	}
+	opts := FilterOptions{DateSource: "ctime"}
+	err := opts.validateDateSource()
+	assert.ErrorRaised(t, "Should reject unknown date source", err, true)
+}
+
+func TestCompilePatternsError(t *testing.T) {
+	opts := FilterOptions{ExcludePattern: []string{"*.tmp", "re:[a-"}}
+	err := opts.compilePatterns()
+	assert.ErrorRaised(t, "Should raise error for invalid exclude pattern", err, true)
+}
+
+func TestRatioForInheritsFromNearestFolder(t *testing.T) {
+	opts := RandomizerOptions{Ratio: 50, FolderRatios: map[string]uint8{"/movies/": 10, "./movies/favourites": 100}}
+	opts.normalizeFolderRatios()
+	tests := []struct {
+		path   string
+		ratio  uint8
+		folder string
+	}{
+		{"track.mp4", 50, ""},
+		{"series/show.mp4", 50, ""},
+		{"movies/film.mp4", 10, "movies"},
+		{"movies/action/film.mp4", 10, "movies"},
+		{"movies/favourites/film.mp4", 100, "movies/favourites"},
+		{"movies/favourites/old/film.mp4", 100, "movies/favourites"},
+	}
+	for _, tt := range tests {
+		ratio, folder := opts.ratioFor(tt.path)
+		assert.Equal(t, "Ratio of "+tt.path, ratio, tt.ratio)
+		assert.Equal(t, "Rule folder of "+tt.path, folder, tt.folder)
+	}
+}
+
+func TestValidateFolderRatio(t *testing.T) {
+	opts := RandomizerOptions{Ratio: 100, FolderRatios: map[string]uint8{"movies": 120}}
+	err := opts.validateRatio()
+	assert.ErrorRaised(t, "Should raise error for folder ratio over 100", err, true)
+}
+
+func TestValidateOrder(t *testing.T) {
+	for _, order := range []string{"", OrderShuffle, OrderRoundRobin, OrderWeighted, OrderSpread} {
+		err := RandomizerOptions{Order: order}.validateOrder()
+		assert.ErrorRaised(t, "Should accept "+order, err, false)
+	}
+	err := RandomizerOptions{Order: "alphabetical"}.validateOrder()
+	assert.ErrorRaised(t, "Should reject unknown order", err, true)
+}
+
+func TestValidateMinGap(t *testing.T) {
+	err := RandomizerOptions{Order: OrderSpread, MinGap: -1}.validateOrder()
+	assert.ErrorRaised(t, "Should reject negative gap", err, true)
+	assert.Equal(t, "Should default gap to 1", RandomizerOptions{}.minGap(), 1)
+	assert.Equal(t, "Should keep set gap", RandomizerOptions{MinGap: 3}.minGap(), 3)
+}
+
+func TestSetSeed(t *testing.T) {
+	opts := RandomizerOptions{Seed: 5}
+	opts.setSeed(0)
+	assert.Equal(t, "Should keep seed of options file", opts.Seed, 5)
+	opts.setSeed(9)
+	assert.Equal(t, "Flag should win over options file", opts.Seed, 9)
+	opts = RandomizerOptions{}
+	opts.setSeed(0)
+	assert.Equal(t, "Should draw a seed", opts.Seed != 0, true)
+}
+
+func TestValidateTargetDuration(t *testing.T) {
+	err := RandomizerOptions{TargetDuration: 5400, Tolerance: 300}.validateTargetDuration()
+	assert.ErrorRaised(t, "Should accept target duration", err, false)
+	err = RandomizerOptions{TargetDuration: 5400, Ratio: 30}.validateTargetDuration()
+	assert.ErrorRaised(t, "Should reject target duration with ratio", err, true)
+	err = RandomizerOptions{TargetDuration: 5400, Tolerance: -1}.validateTargetDuration()
+	assert.ErrorRaised(t, "Should reject negative tolerance", err, true)
+	err = RandomizerOptions{TargetDuration: 5400000}.validateTargetDuration()
+	assert.ErrorRaised(t, "Should reject target duration in milliseconds", err, true)
+	err = RandomizerOptions{TargetDuration: maxTargetDuration, Tolerance: 1}.validateTargetDuration()
+	assert.ErrorRaised(t, "Should count tolerance towards the limit", err, true)
+}
+
+func TestValidateCount(t *testing.T) {
+	err := RandomizerOptions{Count: 20}.validateCount()
+	assert.ErrorRaised(t, "Should accept count", err, false)
+	err = RandomizerOptions{Count: 20, Ratio: 50}.validateCount()
+	assert.ErrorRaised(t, "Should reject count with ratio", err, true)
+	err = RandomizerOptions{Count: 20, TargetDuration: 600}.validateCount()
+	assert.ErrorRaised(t, "Should reject count with target duration", err, true)
+	err = RandomizerOptions{Count: -1}.validateCount()
+	assert.ErrorRaised(t, "Should reject negative count", err, true)
+}
+
+func TestParseBucketQuota(t *testing.T) {
+	var opts RandomizerOptions
+	err := json.Unmarshal([]byte(`{"bucket_quota": {"0-30": "20%", "30-180": 60, ">180": "20 %"}}`), &opts)
+	assert.ErrorRaised(t, "Should unmarshal percentages", err, false)
+	err = opts.parseBucketQuota()
+	assert.ErrorRaised(t, "Should parse bands", err, false)
+	assert.Equal(t, "Should have three bands", len(opts.bands), 3)
+	assert.Equal(t, "Bands should be sorted", opts.bands[0].label, "0-30")
+	assert.Equal(t, "Should read string percent", opts.bands[2].share, 20)
+	assert.Equal(t, "Upper band should be open", opts.bands[2].contains(100000), true)
+	assert.Equal(t, "Band should exclude its upper bound", opts.bands[0].contains(30), false)
+}
+
+func TestParseBucketQuotaErrors(t *testing.T) {
+	tests := []map[string]Percent{
+		{"0-30": 50, "20-180": 50},
+		{"0-30": 50, "30-180": 40},
+		{"thirty": 100},
+		{"180-30": 100},
+	}
+	for _, quota := range tests {
+		opts := RandomizerOptions{BucketQuota: quota}
+		err := opts.parseBucketQuota()
+		assert.ErrorRaised(t, "Should reject invalid quota", err, true)
+	}
+	opts := RandomizerOptions{BucketQuota: map[string]Percent{"<30": 100}, TargetDuration: 600}
+	err := opts.parseBucketQuota()
+	assert.ErrorRaised(t, "Should reject quota with target duration", err, true)
+
+	var p Percent
+	err = json.Unmarshal([]byte(`"many"`), &p)
+	assert.ErrorRaised(t, "Should reject invalid percent", err, true)
+}
+
+func TestBucketEdgesUnmarshal(t *testing.T) {
+	var opts SummaryOptions
+	err := json.Unmarshal([]byte(`{"duration_buckets": [5, 10, 30]}`), &opts)
+	assert.ErrorRaised(t, "Should unmarshal edge list", err, false)
+	assert.EqualSlice(t, "Should read edges", []float64(opts.DurationBuckets), []float64{5, 10, 30})
+
+	err = json.Unmarshal([]byte(`{"duration_buckets": "log"}`), &opts)
+	assert.ErrorRaised(t, "Should unmarshal log spacing", err, false)
+	assert.EqualSlice(t, "Should use log edges", []float64(opts.DurationBuckets), logBucketEdges)
+
+	err = json.Unmarshal([]byte(`{"duration_buckets": "linear"}`), &opts)
+	assert.ErrorRaised(t, "Should reject unknown spacing", err, true)
+}
+
+func TestBucketEdgesValidate(t *testing.T) {
+	assert.ErrorRaised(t, "Should accept increasing edges", BucketEdges{1, 2, 3}.validate(), false)
+	assert.ErrorRaised(t, "Should reject unordered edges", BucketEdges{1, 3, 2}.validate(), true)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// exportTitle names the playlist in formats carrying a title
+const exportTitle = "playmix"
+
+// Exporter writes the ordered media items in one playlist format.
+type Exporter interface {
+	// Name is the value of the format option selecting this exporter.
+	Name() string
+	// Extension is the playlist file extension, also selecting this exporter.
+	Extension() string
+	// Export writes the items in their playlist order.
+	Export(content []MediaItem, options ExportOptions, w io.Writer) error
+}
+
+// ExportOptions are the settings shared by all exporters. Base is empty when
+// absolute paths are written.
+type ExportOptions struct {
+	PlayOptions PlayOptions
+	Base        string
+	Seed        int64
+}
+
+type exporterRegistry struct {
+	exporters []Exporter
+}
+
+func newExporterRegistry(exporters ...Exporter) *exporterRegistry {
+	return &exporterRegistry{exporters: exporters}
+}
+
+func (r *exporterRegistry) byName(name string) (Exporter, bool) {
+	for _, e := range r.exporters {
+		if e.Name() == name {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (r *exporterRegistry) byExtension(ext string) (Exporter, bool) {
+	ext = strings.ToLower(ext)
+	for _, e := range r.exporters {
+		if e.Extension() == ext {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (r *exporterRegistry) names() []string {
+	var names []string
+	for _, e := range r.exporters {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+var exporters = newExporterRegistry(xspfExporter{}, m3uExporter{}, plsExporter{}, asxExporter{}, wplExporter{}, jsonlExporter{}, csvExporter{})
+
+// exportPath gives the path of the item as written in the playlist, relative
+// to base when it is set
+func exportPath(item MediaItem, base string) (string, error) {
+	if item.AbsPath == "" {
+		return item.Location, nil
+	}
+	if base == "" {
+		return filepath.ToSlash(item.AbsPath), nil
+	}
+	rel, err := filepath.Rel(base, item.AbsPath)
+	if err != nil {
+		return "", fmt.Errorf("Cannot make %s relative to %s: %w\n", item.AbsPath, base, err)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// itemOptions gives the options of a track: its own when it was read from a
+// playlist, otherwise the play options
+func itemOptions(item MediaItem, options PlayOptions) []string {
+	if item.Options != nil {
+		return item.Options
+	}
+	return options.trackOptions()
+}
+
+// exportPaths resolves the paths of all items up front, so exporters fail
+// before writing anything
+func exportPaths(content []MediaItem, base string) ([]string, error) {
+	paths := make([]string, len(content))
+	for i, item := range content {
+		path, err := exportPath(item, base)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = path
+	}
+	return paths, nil
+}
+
+// exportPlayList writes the items to the file fn with the given exporter
+func exportPlayList(fn string, exporter Exporter, content []MediaItem, params *Params) error {
+	base, err := params.getPlayListBase(fn)
+	if err != nil {
+		return err
+	}
+	outfile, err := createFile(fn)
+	if err != nil {
+		return err
+	}
+	defer outfile.Close()
+	options := ExportOptions{PlayOptions: params.PlayOptions, Base: base, Seed: params.RandomizerOptions.Seed}
+	return exporter.Export(content, options, outfile)
+}
+
+type xspfExporter struct{}
+
+func (xspfExporter) Name() string { return FormatXspf }
+
+func (xspfExporter) Extension() string { return ".xspf" }
+
+// Export writes the VLC flavoured XSPF; locations are always absolute file URLs
+func (xspfExporter) Export(content []MediaItem, options ExportOptions, w io.Writer) error {
+	playList := buildPlayList(content, options.PlayOptions)
+	playList.annotateSeed(options.Seed)
+	return writePlayList(playList, w)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files of the exporter tests")
+
+func _createExportItems() []MediaItem {
+	return []MediaItem{
+		{AbsPath: "/home/Music/rock/a b.mp4", RelPath: "rock/a b.mp4", Location: getUrlEncodedPath("/home/Music/rock/a b.mp4"), Name: "a b.mp4", Duration: 64.6},
+		{AbsPath: "/home/Music/jazz/c.mp4", RelPath: "jazz/c.mp4", Location: getUrlEncodedPath("/home/Music/jazz/c.mp4"), Name: "c.mp4", Duration: 30},
+	}
+}
+
+func TestExportersGolden(t *testing.T) {
+	options := ExportOptions{PlayOptions: PlayOptions{StartTime: 10, StopTime: 75}, Seed: 42}
+	for _, e := range exporters.exporters {
+		var buf bytes.Buffer
+		err := e.Export(_createExportItems(), options, &buf)
+		assert.ErrorRaised(t, "Should not raise error for "+e.Name(), err, false)
+
+		golden := filepath.Join("testdata", "export", "golden"+e.Extension())
+		if *update {
+			err = os.WriteFile(golden, buf.Bytes(), 0644)
+			assert.ErrorRaised(t, "Should update golden file "+golden, err, false)
+		}
+		expected, err := os.ReadFile(golden)
+		assert.ErrorRaised(t, "Should read golden file "+golden, err, false)
+		assert.Equal(t, "Should match golden file "+golden, buf.String(), string(expected))
+	}
+}
+
+func TestExportersWriteError(t *testing.T) {
+	for _, e := range exporters.exporters {
+		err := e.Export(_createExportItems(), ExportOptions{}, mocks.FakeWriter{})
+		assert.ErrorRaised(t, "Should raise write error for "+e.Name(), err, true)
+	}
+}
+
+func TestExportersRelativePathError(t *testing.T) {
+	for _, e := range exporters.exporters {
+		if e.Name() == FormatXspf {
+			continue
+		}
+		err := e.Export(_createExportItems(), ExportOptions{Base: "relative/base"}, &bytes.Buffer{})
+		assert.ErrorRaised(t, "Should raise error for unrelatable base for "+e.Name(), err, true)
+	}
+}
+
+func TestExporterRegistry(t *testing.T) {
+	e, found := exporters.byExtension(".WPL")
+	assert.Equal(t, "Should find exporter case insensitively", found, true)
+	assert.Equal(t, "Should find wpl exporter", e.Name(), FormatWpl)
+	_, found = exporters.byName("wav")
+	assert.Equal(t, "Should not find unknown format", found, false)
+	assert.EqualSlice(t, "Should list format names", exporters.names(), []string{FormatXspf, FormatM3u8, FormatPls, FormatAsx, FormatWpl, FormatJsonl, FormatCsv})
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// FLV files start with a 9 byte header ("FLV", version, flags, header size)
+// followed by [previous tag size uint32][tag] pairs. Tags have an 11 byte header:
+// type, data size (3 bytes), timestamp (3 bytes + 1 extension byte) and stream id.
+// See https://rtmp.veriskope.com/pdf/video_file_format_spec_v10.pdf
+const (
+	flvHeaderSize    = 9
+	flvTagHeaderSize = 11
+	flvTagScript     = 18
+	flvMaxScriptSize = 1 << 20
+	flvMaxScanTags   = 16
+)
+
+// AMF0 type markers
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfReference   = 0x07
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0A
+	amfDate        = 0x0B
+	amfLongString  = 0x0C
+)
+
+type flvTag struct {
+	tagType   byte
+	dataSize  int64
+	timestamp uint32
+}
+
+type flvReader struct {
+	r    io.ReaderAt
+	size int64
+}
+
+func (f flvReader) readTag(off int64) (flvTag, error) {
+	buf := make([]byte, flvTagHeaderSize)
+	if _, err := f.r.ReadAt(buf, off); err != nil {
+		return flvTag{}, err
+	}
+	tag := flvTag{
+		tagType:   buf[0] & 0x1F,
+		dataSize:  int64(buf[1])<<16 | int64(buf[2])<<8 | int64(buf[3]),
+		timestamp: uint32(buf[7])<<24 | uint32(buf[4])<<16 | uint32(buf[5])<<8 | uint32(buf[6]),
+	}
+	return tag, nil
+}
+
+// metaDataDuration looks for the onMetaData script tag among the first tags
+// and returns its duration property.
+func (f flvReader) metaDataDuration(start int64) (float64, error) {
+	off := start
+	for i := 0; i < flvMaxScanTags && off+flvTagHeaderSize <= f.size; i++ {
+		tag, err := f.readTag(off)
+		if err != nil {
+			return 0, err
+		}
+		if tag.tagType == flvTagScript && tag.dataSize <= flvMaxScriptSize {
+			data := make([]byte, tag.dataSize)
+			if _, err := f.r.ReadAt(data, off+flvTagHeaderSize); err != nil {
+				return 0, err
+			}
+			amf := &amfReader{data: data}
+			name, err := amf.readValue()
+			if err == nil && name == "onMetaData" {
+				meta, err := amf.readValue()
+				if err != nil {
+					return 0, err
+				}
+				if props, ok := meta.(map[string]any); ok {
+					if duration, ok := props["duration"].(float64); ok && duration > 0 {
+						return duration, nil
+					}
+				}
+			}
+		}
+		off += flvTagHeaderSize + tag.dataSize + 4
+	}
+	return 0, fmt.Errorf("onMetaData duration not found")
+}
+
+// lastTagDuration uses the trailing previous tag size to find the last tag
+// and returns its timestamp.
+func (f flvReader) lastTagDuration() (float64, error) {
+	buf := make([]byte, 4)
+	if _, err := f.r.ReadAt(buf, f.size-4); err != nil {
+		return 0, err
+	}
+	lastTagSize := int64(binary.BigEndian.Uint32(buf))
+	off := f.size - 4 - lastTagSize
+	if lastTagSize < flvTagHeaderSize || off < flvHeaderSize {
+		return 0, fmt.Errorf("Invalid last tag size %d", lastTagSize)
+	}
+	tag, err := f.readTag(off)
+	if err != nil {
+		return 0, err
+	}
+	if tag.dataSize+flvTagHeaderSize != lastTagSize {
+		return 0, fmt.Errorf("Last tag size mismatch")
+	}
+	return float64(tag.timestamp) / 1000, nil
+}
+
+func (f flvReader) getDuration() (float64, error) {
+	header := make([]byte, flvHeaderSize)
+	if _, err := f.r.ReadAt(header, 0); err != nil {
+		return 0, err
+	}
+	if string(header[0:3]) != "FLV" {
+		return 0, fmt.Errorf("FLV header not found. Is this flv?")
+	}
+	dataOffset := int64(binary.BigEndian.Uint32(header[5:9]))
+	duration, err := f.metaDataDuration(dataOffset + 4)
+	if err == nil {
+		return duration, nil
+	}
+	return f.lastTagDuration()
+}
+
+func getFlvDuration(r io.ReaderAt, size int64) (float64, error) {
+	reader := flvReader{r: r, size: size}
+	return reader.getDuration()
+}
+
+type amfReader struct {
+	data []byte
+	pos  int
+}
+
+func (a *amfReader) next(n int) ([]byte, error) {
+	if n < 0 || a.pos+n > len(a.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := a.data[a.pos : a.pos+n]
+	a.pos += n
+	return b, nil
+}
+
+func (a *amfReader) readString(lenSize int) (string, error) {
+	b, err := a.next(lenSize)
+	if err != nil {
+		return "", err
+	}
+	var n int
+	if lenSize == 2 {
+		n = int(binary.BigEndian.Uint16(b))
+	} else {
+		n = int(binary.BigEndian.Uint32(b))
+	}
+	s, err := a.next(n)
+	return string(s), err
+}
+
+// readProperties reads key/value pairs until the object end marker.
+func (a *amfReader) readProperties() (map[string]any, error) {
+	props := map[string]any{}
+	for {
+		key, err := a.readString(2)
+		if err != nil {
+			return props, err
+		}
+		if key == "" && a.pos < len(a.data) && a.data[a.pos] == amfObjectEnd {
+			a.pos++
+			return props, nil
+		}
+		value, err := a.readValue()
+		if err != nil {
+			return props, err
+		}
+		props[key] = value
+	}
+}
+
+func (a *amfReader) readValue() (any, error) {
+	marker, err := a.next(1)
+	if err != nil {
+		return nil, err
+	}
+	switch marker[0] {
+	case amfNumber:
+		b, err := a.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case amfBoolean:
+		b, err := a.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case amfString:
+		return a.readString(2)
+	case amfLongString:
+		return a.readString(4)
+	case amfObject:
+		return a.readProperties()
+	case amfECMAArray:
+		// the count is only a hint, the array is terminated like an object
+		if _, err := a.next(4); err != nil {
+			return nil, err
+		}
+		return a.readProperties()
+	case amfStrictArray:
+		b, err := a.next(4)
+		if err != nil {
+			return nil, err
+		}
+		count := int(binary.BigEndian.Uint32(b))
+		values := []any{}
+		for i := 0; i < count; i++ {
+			value, err := a.readValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case amfDate:
+		b, err := a.next(10)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b[0:8])), nil
+	case amfReference:
+		_, err := a.next(2)
+		return nil, err
+	case amfNull, amfUndefined:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("Unsupported AMF0 type %d", marker[0])
+}
+
+type flvProber struct{}
+
+func (flvProber) Name() string { return "flv" }
+
+func (flvProber) Extensions() []string { return []string{".flv"} }
+
+func (flvProber) Match(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("FLV"))
+}
+
+func (flvProber) Probe(r io.ReaderAt, size int64) (MediaInfo, error) {
+	duration, err := getFlvDuration(r, size)
+	return MediaInfo{Duration: duration}, err
+}
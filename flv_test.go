@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestGetFlvDurationMetaData(t *testing.T) {
+	data := mocks.CreateFlvData(12.5, 5000)
+	duration, err := getFlvDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should use onMetaData duration", duration, 12.5)
+}
+
+func TestGetFlvDurationLastTag(t *testing.T) {
+	data := mocks.CreateFlvData(0, 7500)
+	duration, err := getFlvDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should fall back to last tag timestamp", duration, 7.5)
+}
+
+func TestGetFlvDurationNotFlv(t *testing.T) {
+	data := mocks.CreateData(60)
+	_, err := getFlvDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should raise error for mp4 data", err, true)
+}
+
+func TestGetFlvDurationTruncated(t *testing.T) {
+	data := mocks.CreateFlvData(0, 3000)
+	data = data[:len(data)-2]
+	_, err := getFlvDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should raise error for truncated file", err, true)
+}
+
+func TestAmfReaderObject(t *testing.T) {
+	data := []byte{0x03, 0x00, 0x01, 'a', 0x01, 0x01, 0x00, 0x00, 0x09}
+	amf := &amfReader{data: data}
+	value, err := amf.readValue()
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	props := value.(map[string]any)
+	assert.Equal(t, "Should read boolean property", props["a"].(bool), true)
+}
+
+func TestGetDurationFlv(t *testing.T) {
+	fn := "clip.flv"
+	f := fstest.MapFS{
+		fn: {
+			Data:    mocks.CreateFlvData(42, 42000),
+			Mode:    0755,
+			ModTime: time.Now(),
+		},
+	}
+	duration, err := getDuration(f, fn)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should be 42 seconds", duration, 42)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+// histogram geometry of the inline SVG, in pixels
+const (
+	histogramWidth  = 640
+	histogramHeight = 200
+	histogramLabel  = 20
+	histogramGap    = 8
+)
+
+type histogramBar struct {
+	X, Y, Width, Height, LabelX float64
+	Label                       string
+	Count                       int
+}
+
+type reportFolderRow struct {
+	Folder   string
+	Tracks   int
+	Duration float64
+}
+
+// reportTrack is a track of the playlist with its location marked safe for
+// the link, as playmix builds file URLs itself
+type reportTrack struct {
+	Title    string
+	Location template.URL
+	Duration float64
+}
+
+type htmlReport struct {
+	Report
+	Bars        []histogramBar
+	ChartWidth  int
+	ChartHeight int
+	FolderRows  []reportFolderRow
+	Tracks      []reportTrack
+}
+
+// histogramBars scales the bucket counts to the chart height
+func histogramBars(buckets []ReportBucket) []histogramBar {
+	if len(buckets) == 0 {
+		return nil
+	}
+	highest := 1
+	for _, b := range buckets {
+		highest = max(highest, b.Count)
+	}
+	slot := float64(histogramWidth) / float64(len(buckets))
+	var bars []histogramBar
+	for i, b := range buckets {
+		height := float64(b.Count) / float64(highest) * histogramHeight
+		bars = append(bars, histogramBar{
+			X:      float64(i)*slot + histogramGap/2,
+			Y:      histogramHeight - height,
+			Width:  slot - histogramGap,
+			Height: height,
+			LabelX: float64(i)*slot + slot/2,
+			Label:  b.Label,
+			Count:  b.Count,
+		})
+	}
+	return bars
+}
+
+// trackFolder gives the folder of the file a track location points to; other
+// locations are grouped by their URL path
+func trackFolder(location string) string {
+	p, err := locationPath(location, "")
+	if err == nil && p != "" {
+		return filepath.Dir(p)
+	}
+	return path.Dir(location)
+}
+
+// folderRows groups the tracks of the playlist by the folder of their file
+func folderRows(pl *PlayList) []reportFolderRow {
+	rows := map[string]*reportFolderRow{}
+	for _, track := range pl.Tl.Tracks {
+		folder := trackFolder(track.Location)
+		if _, found := rows[folder]; !found {
+			rows[folder] = &reportFolderRow{Folder: folder}
+		}
+		rows[folder].Tracks++
+		rows[folder].Duration += track.Duration
+	}
+	var sorted []reportFolderRow
+	for _, row := range rows {
+		sorted = append(sorted, *row)
+	}
+	slices.SortFunc(sorted, func(a, b reportFolderRow) int {
+		if a.Tracks != b.Tracks {
+			return b.Tracks - a.Tracks
+		}
+		if a.Folder < b.Folder {
+			return -1
+		}
+		return 1
+	})
+	return sorted
+}
+
+// minutes formats seconds as m:ss, rounding to whole seconds first
+func minutes(seconds float64) string {
+	total := int(math.Round(seconds))
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+func reportTracks(pl *PlayList) []reportTrack {
+	var tracks []reportTrack
+	for _, track := range pl.Tl.Tracks {
+		tracks = append(tracks, reportTrack{
+			Title:    track.Title,
+			Location: template.URL(track.Location),
+			Duration: track.Duration,
+		})
+	}
+	return tracks
+}
+
+var reportFuncs = template.FuncMap{
+	"minutes": minutes,
+	"inc":     func(i int) int { return i + 1 },
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>playmix report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+td.num { text-align: right; }
+svg text { font-size: 12px; }
+rect.bar { fill: #4a7ab5; }
+</style>
+</head>
+<body>
+<h1>playmix report</h1>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05"}} UTC with seed {{.Seed}}.</p>
+<table>
+<tr><th>Scanned</th><td class="num">{{.Scanned}}</td></tr>
+<tr><th>Selected</th><td class="num">{{.Selected}}</td></tr>
+<tr><th>Required ratio</th><td class="num">{{.RequiredRatio}}%</td></tr>
+<tr><th>Actual ratio</th><td class="num">{{printf "%.2f" .ActualRatio}}%</td></tr>
+<tr><th>Total duration</th><td class="num">{{minutes .TotalDuration}}</td></tr>
+</table>
+<h2>Duration distribution</h2>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{.ChartWidth}}" height="{{.ChartHeight}}" role="img">
+{{- range .Bars}}
+<rect class="bar" x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="{{.Height}}"><title>{{.Label}} seconds: {{.Count}}</title></rect>
+<text x="{{.LabelX}}" y="{{$.ChartHeight}}" text-anchor="middle" dy="-4">{{.Label}}</text>
+{{- end}}
+</svg>
+<h2>Scanned folders</h2>
+<table>
+<tr><th>Folder</th><th>Scanned</th><th>Selected</th><th>Actual ratio</th></tr>
+{{- range .Directories}}
+<tr><td>{{.Dir}}</td><td class="num">{{.Scanned}}</td><td class="num">{{.Selected}}</td><td class="num">{{printf "%.2f" .ActualRatio}}%</td></tr>
+{{- end}}
+</table>
+<h2>Selected folders</h2>
+<table>
+<tr><th>Folder</th><th>Tracks</th><th>Duration</th></tr>
+{{- range .FolderRows}}
+<tr><td>{{.Folder}}</td><td class="num">{{.Tracks}}</td><td class="num">{{minutes .Duration}}</td></tr>
+{{- end}}
+</table>
+<h2>Tracks</h2>
+<table>
+<tr><th>#</th><th>Title</th><th>Duration</th></tr>
+{{- range $i, $t := .Tracks}}
+<tr><td class="num">{{inc $i}}</td><td><a href="{{$t.Location}}">{{$t.Title}}</a></td><td class="num">{{minutes $t.Duration}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// writeHTMLReport writes a self-contained page with the summary, a duration
+// histogram, the scanned and selected count of every folder, the folders of the
+// selected tracks and the track order
+func writeHTMLReport(r Report, pl *PlayList, w io.Writer) error {
+	data := htmlReport{
+		Report:      r,
+		Bars:        histogramBars(r.Buckets),
+		ChartWidth:  histogramWidth,
+		ChartHeight: histogramHeight + histogramLabel,
+		FolderRows:  folderRows(pl),
+		Tracks:      reportTracks(pl),
+	}
+	err := htmlReportTemplate.Execute(w, data)
+	if err != nil {
+		return fmt.Errorf("Error writing html report: %w\n", err)
+	}
+	return nil
+}
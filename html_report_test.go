@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"strings"
+	"testing"
+)
+
+func _createReportPlayList() *PlayList {
+	content := []MediaItem{
+		{Location: getUrlEncodedPath("/home/Music/rock/a b.mp4"), Name: "a b.mp4", Duration: 65},
+		{Location: getUrlEncodedPath("/home/Music/jazz/c.mp4"), Name: "c.mp4", Duration: 30},
+		{Location: getUrlEncodedPath("/home/Music/rock/d.mp4"), Name: "d.mp4", Duration: 20},
+	}
+	return buildPlayList(content, PlayOptions{})
+}
+
+func TestHistogramBars(t *testing.T) {
+	bars := histogramBars([]ReportBucket{{Label: "<5", Count: 2}, {Label: "5-10", Count: 4}, {Label: "10<", Count: 0}})
+	assert.Equal(t, "Should create bar per bucket", len(bars), 3)
+	assert.Equal(t, "Highest bar should fill chart", bars[1].Height, histogramHeight)
+	assert.Equal(t, "Bar should scale to highest", bars[0].Height, histogramHeight/2)
+	assert.Equal(t, "Empty bucket should have no height", bars[2].Height, 0)
+	assert.Equal(t, "Empty bucket should sit on baseline", bars[2].Y, histogramHeight)
+	assert.Equal(t, "No buckets should give no bars", len(histogramBars(nil)), 0)
+}
+
+func TestFolderRows(t *testing.T) {
+	rows := folderRows(_createReportPlayList())
+	assert.Equal(t, "Should group by folder", len(rows), 2)
+	assert.Equal(t, "Should put largest folder first", rows[0].Folder, filepath.FromSlash("/home/Music/rock"))
+	assert.Equal(t, "Should count tracks", rows[0].Tracks, 2)
+	assert.Equal(t, "Should sum durations", rows[0].Duration, 85)
+}
+
+func TestMinutes(t *testing.T) {
+	assert.Equal(t, "Should round before splitting", minutes(59.6), "1:00")
+	assert.Equal(t, "Should format minutes", minutes(125.2), "2:05")
+	assert.Equal(t, "Should format zero", minutes(0), "0:00")
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeHTMLReport(_createSummary().report(), _createReportPlayList(), &buf)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	html := buf.String()
+	assert.Equal(t, "Should inline svg chart", strings.Contains(html, "<svg"), true)
+	assert.Equal(t, "Should draw bars", strings.Count(html, `<rect class="bar"`), len(_createSummary().report().Buckets))
+	link := `<a href="` + getUrlEncodedPath("/home/Music/jazz/c.mp4") + `">c.mp4</a>`
+	assert.Equal(t, "Should link tracks to file urls", strings.Contains(html, link), true)
+	assert.Equal(t, "Should not filter file urls", strings.Contains(html, "ZgotmplZ"), false)
+	assert.Equal(t, "Should list folders", strings.Contains(html, "<td>/home/Music/rock</td>"), true)
+	rock := `<tr><td>Music/rock</td><td class="num">2</td><td class="num">1</td><td class="num">50.00%</td></tr>`
+	assert.Equal(t, "Should list scanned and selected per folder", strings.Contains(html, rock), true)
+	jazz := `<tr><td>Music/jazz</td><td class="num">1</td><td class="num">0</td><td class="num">0.00%</td></tr>`
+	assert.Equal(t, "Should list folders without selected files", strings.Contains(html, jazz), true)
+	assert.Equal(t, "Should keep track order", strings.Index(html, "a b.mp4") < strings.Index(html, "d.mp4"), true)
+
+	err = writeHTMLReport(_createSummary().report(), _createReportPlayList(), mocks.FakeWriter{})
+	assert.ErrorRaised(t, "Should raise write error", err, true)
+}
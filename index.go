@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	indexVersion         = 3
+	defaultIndexFileName = "playmix-index.json"
+)
+
+// IndexEntry holds the probed metadata of a file, valid as long as
+// its size and modification time are unchanged.
+type IndexEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	MediaInfo
+}
+
+// MediaIndex caches probe results between runs, keyed by path relative to the media root.
+type MediaIndex struct {
+	Version   int                   `json:"version"`
+	MediaPath string                `json:"media_path"`
+	Entries   map[string]IndexEntry `json:"entries"`
+}
+
+func newMediaIndex(mediaPath string) *MediaIndex {
+	return &MediaIndex{Version: indexVersion, MediaPath: mediaPath, Entries: map[string]IndexEntry{}}
+}
+
+// loadIndex reads the index file from the same os path save writes it to. A
+// missing file, another media path or an older index version gives an empty
+// index, as does rebuild.
+func loadIndex(fn string, mediaPath string, rebuild bool) (*MediaIndex, error) {
+	if rebuild {
+		return newMediaIndex(mediaPath), nil
+	}
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newMediaIndex(mediaPath), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Index file cannot be read: %w", err)
+	}
+	index := &MediaIndex{}
+	err = json.Unmarshal(data, index)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling index file: %w", err)
+	}
+	if index.Version != indexVersion || index.MediaPath != mediaPath || index.Entries == nil {
+		log.Printf("Index %s is outdated, rebuilding it\n", fn)
+		return newMediaIndex(mediaPath), nil
+	}
+	return index, nil
+}
+
+func (m *MediaIndex) lookup(c candidate) (IndexEntry, bool) {
+	if m == nil {
+		return IndexEntry{}, false
+	}
+	entry, found := m.Entries[c.path]
+	if !found || entry.Size != c.size || !entry.ModTime.Equal(c.modTime) {
+		return IndexEntry{}, false
+	}
+	return entry, true
+}
+
+func (m *MediaIndex) store(c candidate, info MediaInfo) {
+	if m == nil {
+		return
+	}
+	m.Entries[c.path] = IndexEntry{Size: c.size, ModTime: c.modTime.UTC(), MediaInfo: info}
+}
+
+// prune drops the entries of files no longer found under the media root and
+// returns how many were dropped. Files under skipped folders or outside the
+// filters of this run are kept, so widening the filters later reuses them.
+func (m *MediaIndex) prune(fsys fs.FS) int {
+	if m == nil {
+		return 0
+	}
+	pruned := 0
+	for path := range m.Entries {
+		if _, err := fs.Stat(fsys, path); errors.Is(err, fs.ErrNotExist) {
+			delete(m.Entries, path)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// save writes the index through a temporary file so an interrupted run
+// never leaves a truncated index behind.
+func (m *MediaIndex) save(fn string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("Error marshalling index: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fn), filepath.Base(fn)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Error creating index file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing index file: %w", err)
+	}
+	return os.Rename(tmp.Name(), fn)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func _writeIndexFile(t *testing.T, data string) string {
+	fn := filepath.Join(t.TempDir(), "playmix-index.json")
+	err := os.WriteFile(fn, []byte(data), 0644)
+	assert.ErrorRaised(t, "Should write index file", err, false)
+	return fn
+}
+
+func TestLoadIndexMissingFile(t *testing.T) {
+	index, err := loadIndex(filepath.Join(t.TempDir(), "playmix-index.json"), "/media/", false)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should start empty", len(index.Entries), 0)
+	assert.Equal(t, "Should set media path", index.MediaPath, "/media/")
+}
+
+func TestLoadIndex(t *testing.T) {
+	fn := _writeIndexFile(t, `{"version": 3, "media_path": "/media/", "entries": {"a/track.mp4": {"size": 10, "mod_time": "2023-06-16T10:00:00Z", "duration": 60}}}`)
+	index, err := loadIndex(fn, "/media/", false)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should load entries", index.Entries["a/track.mp4"].Duration, 60)
+
+	index, err = loadIndex(fn, "/media/", true)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Rebuild should start empty", len(index.Entries), 0)
+
+	index, err = loadIndex(fn, "/other/", false)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Other media path should start empty", len(index.Entries), 0)
+}
+
+func TestLoadIndexOldVersion(t *testing.T) {
+	fn := _writeIndexFile(t, `{"version": 0, "media_path": "/media/", "entries": {"a.mp4": {"size": 10, "duration": 60}}}`)
+	index, err := loadIndex(fn, "/media/", false)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Old version should start empty", len(index.Entries), 0)
+}
+
+func TestLoadIndexUnmarshalError(t *testing.T) {
+	_, err := loadIndex(_writeIndexFile(t, "{"), "/media/", false)
+	assert.ErrorRaised(t, "Should raise unmarshal error", err, true)
+}
+
+func TestLoadIndexOutsideWorkingDirectory(t *testing.T) {
+	fn := _writeIndexFile(t, `{"version": 3, "media_path": "/media/", "entries": {"a.mp4": {"size": 10, "duration": 60}}}`)
+	wd, err := os.Getwd()
+	assert.ErrorRaised(t, "Should get working directory", err, false)
+	rel, err := filepath.Rel(wd, fn)
+	assert.ErrorRaised(t, "Should make relative path", err, false)
+	for _, path := range []string{fn, rel} {
+		index, err := loadIndex(path, "/media/", false)
+		assert.ErrorRaised(t, "Should read index from "+path, err, false)
+		assert.Equal(t, "Should load entries from "+path, index.Entries["a.mp4"].Duration, 60)
+	}
+}
+
+func TestIndexPrune(t *testing.T) {
+	index := newMediaIndex("/media/")
+	index.store(candidate{path: "kept.mp4"}, MediaInfo{Duration: 1})
+	index.store(candidate{path: "skipped/kept.mp4"}, MediaInfo{Duration: 2})
+	index.store(candidate{path: "gone.mp4"}, MediaInfo{Duration: 3})
+	fsys := fstest.MapFS{
+		"kept.mp4":         {Data: []byte{}, Mode: 0755},
+		"skipped/kept.mp4": {Data: []byte{}, Mode: 0755},
+	}
+	assert.Equal(t, "Should prune missing file", index.prune(fsys), 1)
+	_, found := index.Entries["gone.mp4"]
+	assert.Equal(t, "Should drop missing file", found, false)
+	assert.Equal(t, "Should keep existing files", len(index.Entries), 2)
+
+	var nilIndex *MediaIndex
+	assert.Equal(t, "Nil index should prune nothing", nilIndex.prune(fsys), 0)
+}
+
+func TestIndexLookup(t *testing.T) {
+	modTime := time.Date(2023, 6, 16, 10, 0, 0, 0, time.UTC)
+	c := candidate{path: "a.mp4", size: 10, modTime: modTime}
+	index := newMediaIndex("/media/")
+	index.store(c, MediaInfo{Duration: 42})
+
+	entry, found := index.lookup(c)
+	assert.Equal(t, "Should find stored entry", found, true)
+	assert.Equal(t, "Should return duration", entry.Duration, 42)
+
+	_, found = index.lookup(candidate{path: "a.mp4", size: 11, modTime: modTime})
+	assert.Equal(t, "Changed size should miss", found, false)
+	_, found = index.lookup(candidate{path: "a.mp4", size: 10, modTime: modTime.Add(time.Second)})
+	assert.Equal(t, "Changed mtime should miss", found, false)
+
+	var nilIndex *MediaIndex
+	_, found = nilIndex.lookup(c)
+	assert.Equal(t, "Nil index should miss", found, false)
+}
+
+func TestIndexSave(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "playmix-index.json")
+	index := newMediaIndex("/media/")
+	index.store(candidate{path: "a.mp4", size: 10, modTime: time.Date(2023, 6, 16, 10, 0, 0, 0, time.UTC)}, MediaInfo{Duration: 42})
+	err := index.save(fn)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+
+	loaded, err := loadIndex(fn, "/media/", false)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should round trip entries", loaded.Entries["a.mp4"].Duration, 42)
+
+	err = index.save(filepath.Join(dir, "missing", "index.json"))
+	assert.ErrorRaised(t, "Should raise error for missing directory", err, true)
+}
+
+func TestCollectMediaContentUsesIndex(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"cached.mp4":  {Data: mocks.CreateData(60), Mode: 0755, ModTime: modTime},
+		"changed.mp4": {Data: mocks.CreateData(70), Mode: 0755, ModTime: modTime},
+	}
+	index := newMediaIndex("/home/Music/")
+	size := int64(len(mocks.CreateData(60)))
+	index.store(candidate{path: "cached.mp4", size: size, modTime: modTime}, MediaInfo{Duration: 999})
+	index.store(candidate{path: "changed.mp4", size: size, modTime: modTime.Add(-time.Hour)}, MediaInfo{Duration: 999})
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+		index:             index,
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should use cached duration", items[0].Duration, 999)
+	assert.Equal(t, "Should reprobe changed file", items[1].Duration, 70)
+	assert.Equal(t, "Should count cache hits", summary.cached, 1)
+	assert.Equal(t, "Should refresh changed entry", index.Entries["changed.mp4"].Duration, 70)
+}
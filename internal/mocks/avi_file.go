@@ -0,0 +1,57 @@
+package mocks
+
+import "encoding/binary"
+
+// RIFF chunks are [fourcc][size uint32 LE][data]
+// AVI header layout used here:
+// RIFF AVI
+// - LIST hdrl
+//   - avih (56 bytes: dwMicroSecPerFrame ... dwTotalFrames ...)
+//   - LIST odml (only for OpenDML files)
+//     - dmlh (dwTotalFrames)
+// - LIST movi (empty)
+
+func addIntAs4BytesLE(b []uint8, number int) []uint8 {
+	return binary.LittleEndian.AppendUint32(b, uint32(number))
+}
+
+func createChunk(id string, data []uint8) []uint8 {
+	chunk := addStringAsByte(nil, id)
+	chunk = addIntAs4BytesLE(chunk, len(data))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func createList(listType string, chunks ...[]uint8) []uint8 {
+	data := addStringAsByte(nil, listType)
+	for _, c := range chunks {
+		data = append(data, c...)
+	}
+	return createChunk("LIST", data)
+}
+
+func createAvih(microSecPerFrame, totalFrames int) []uint8 {
+	var data []uint8
+	data = addIntAs4BytesLE(data, microSecPerFrame)
+	data = addPadding(data, 12)
+	data = addIntAs4BytesLE(data, totalFrames)
+	data = addPadding(data, 36)
+	return createChunk("avih", data)
+}
+
+// CreateAviData returns an AVI header for a 25 fps stream with the given frame count.
+// If odmlFrames is greater than zero an OpenDML dmlh chunk is added with that total.
+func CreateAviData(totalFrames int, odmlFrames int) []uint8 {
+	hdrl := [][]uint8{createAvih(40000, totalFrames)}
+	if odmlFrames > 0 {
+		dmlh := createChunk("dmlh", addPadding(addIntAs4BytesLE(nil, odmlFrames), 244))
+		hdrl = append(hdrl, createList("odml", dmlh))
+	}
+	data := addStringAsByte(nil, "AVI ")
+	data = append(data, createList("hdrl", hdrl...)...)
+	data = append(data, createList("movi")...)
+	return createChunk("RIFF", data)
+}
@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// FLV layout used here:
+// header: "FLV", version 1, flags 5 (audio+video), header size 9
+// previous tag size 0
+// optional script tag: "onMetaData" ECMA array {"width": 640, "stereo": true, "duration": x}
+// video tags with increasing timestamps, each followed by its previous tag size
+
+func addAmfString(b []uint8, s string) []uint8 {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return addStringAsByte(b, s)
+}
+
+func addAmfNumber(b []uint8, key string, value float64) []uint8 {
+	b = addAmfString(b, key)
+	b = append(b, 0x00)
+	return binary.BigEndian.AppendUint64(b, math.Float64bits(value))
+}
+
+func createFlvTag(tagType uint8, timestamp int, data []uint8) []uint8 {
+	tag := []uint8{tagType}
+	tag = append(tag, uint8(len(data)>>16), uint8(len(data)>>8), uint8(len(data)))
+	tag = append(tag, uint8(timestamp>>16), uint8(timestamp>>8), uint8(timestamp), uint8(timestamp>>24))
+	tag = addPadding(tag, 3)
+	tag = append(tag, data...)
+	return addIntAs4Bytes(tag, len(tag))
+}
+
+func createMetaData(duration float64) []uint8 {
+	data := []uint8{0x02}
+	data = addAmfString(data, "onMetaData")
+	data = append(data, 0x08)
+	data = addIntAs4Bytes(data, 3)
+	data = addAmfNumber(data, "width", 640)
+	data = addAmfString(data, "stereo")
+	data = append(data, 0x01, 0x01)
+	data = addAmfNumber(data, "duration", duration)
+	data = append(data, 0x00, 0x00, 0x09)
+	return data
+}
+
+// CreateFlvData returns an FLV file with video tags one second apart up to lastTimestamp (ms).
+// When metaDuration is greater than zero an onMetaData script tag is written first.
+func CreateFlvData(metaDuration float64, lastTimestamp int) []uint8 {
+	data := addStringAsByte(nil, "FLV")
+	data = append(data, 0x01, 0x05)
+	data = addIntAs4Bytes(data, 9)
+	data = addIntAs4Bytes(data, 0)
+	if metaDuration > 0 {
+		data = append(data, createFlvTag(18, 0, createMetaData(metaDuration))...)
+	}
+	for ts := 0; ts < lastTimestamp; ts += 1000 {
+		data = append(data, createFlvTag(9, ts, []uint8{0x17, 0x01})...)
+	}
+	data = append(data, createFlvTag(9, lastTimestamp, []uint8{0x17, 0x01})...)
+	return data
+}
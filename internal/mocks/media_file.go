@@ -3,6 +3,7 @@ package mocks
 import (
 	"fmt"
 	"os"
+	"time"
 )
 
 const (
@@ -130,6 +131,77 @@ func CreateData(seconds int) []uint8 {
 	return data
 }
 
+// CreateDataWithCreationTime returns an mp4 file whose mvhd box (version 0)
+// records created, stored as seconds since 1904-01-01 UTC
+func CreateDataWithCreationTime(seconds int, created time.Time) []uint8 {
+	data := CreateData(seconds)
+	epoch := time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	creation := addIntAs4Bytes(nil, int(created.Sub(epoch)/time.Second))
+	// ftyp (16), moov header (8), mvhd header (8), version and flags (4)
+	copy(data[36:40], creation)
+	return data
+}
+
+func createBox(name string, children ...[]uint8) []uint8 {
+	var payload []uint8
+	for _, child := range children {
+		payload = append(payload, child...)
+	}
+	return append(createHeader(len(payload)+BoxHeaderSize, name), payload...)
+}
+
+// tkhd holds the resolution as 16.16 fixed point numbers in its last 8 bytes
+func createTkhdBox(width, height int) []uint8 {
+	data := addPadding(nil, 76)
+	data = addIntAs4Bytes(data, width<<16)
+	data = addIntAs4Bytes(data, height<<16)
+	return createBox("tkhd", data)
+}
+
+func createMdhdBox(scale, duration int) []uint8 {
+	data := addPadding(nil, 12)
+	data = addIntAs4Bytes(data, scale)
+	data = addIntAs4Bytes(data, duration)
+	return createBox("mdhd", addPadding(data, 4))
+}
+
+// hdlr needs at least one byte of name after the handler type and reserved fields
+func createHdlrBox(handler string) []uint8 {
+	data := addPadding(nil, 8)
+	data = addStringAsByte(data, handler)
+	return createBox("hdlr", addPadding(data, 13))
+}
+
+func createVideoTrak(seconds, width, height int, codec string, fps int) []uint8 {
+	scale := fps * timeScale
+	stsd := addIntAs4Bytes(addPadding(nil, 4), 1)
+	stsd = append(stsd, createHeader(16, codec)...)
+	stsd = addPadding(stsd, 8)
+	stts := addIntAs4Bytes(addPadding(nil, 4), 1)
+	stts = addIntAs4Bytes(stts, seconds*fps)
+	stts = addIntAs4Bytes(stts, timeScale)
+	stbl := createBox("stbl", createBox("stsd", stsd), createBox("stts", stts))
+	mdia := createBox("mdia", createMdhdBox(scale, seconds*scale), createHdlrBox("vide"), createBox("minf", stbl))
+	return createBox("trak", createTkhdBox(width, height), mdia)
+}
+
+func createAudioTrak(seconds int) []uint8 {
+	mdia := createBox("mdia", createMdhdBox(44100, seconds*44100), createHdlrBox("soun"))
+	return createBox("trak", createTkhdBox(0, 0), mdia)
+}
+
+// CreateDataWithTracks returns an mp4 file with a video track and, when audio
+// is set, an audio track
+func CreateDataWithTracks(seconds, width, height int, codec string, fps int, audio bool) []uint8 {
+	mvhd := createMoovBox(seconds)[BoxHeaderSize:]
+	traks := createVideoTrak(seconds, width, height, codec, fps)
+	if audio {
+		traks = append(traks, createAudioTrak(seconds)...)
+	}
+	data := createFtypBox()
+	return append(data, createBox("moov", mvhd, traks)...)
+}
+
 func WriteFile(seconds int, path string) []uint8 {
 	f := CreateData(seconds)
 	fmt.Printf("data is: %v\n", f)
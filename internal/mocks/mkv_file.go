@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// EBML elements are encoded as [id][size vint][data]
+// ids keep their length marker, e.g. Segment -> 0x18538067
+// sizes used here are all 1 byte vints: 0x80 | size
+// except the Segment, which is written with unknown size (0x01FFFFFFFFFFFFFF)
+// so the reader has to fall back to the file size.
+
+func addEBMLElement(b []uint8, id []uint8, data []uint8) []uint8 {
+	b = append(b, id...)
+	b = append(b, uint8(0x80|len(data)))
+	b = append(b, data...)
+	return b
+}
+
+func createEBMLHeader(docType string) []uint8 {
+	var content []uint8
+	content = addEBMLElement(content, []uint8{0x42, 0x82}, addStringAsByte(nil, docType))
+	return addEBMLElement(nil, []uint8{0x1A, 0x45, 0xDF, 0xA3}, content)
+}
+
+func createInfo(seconds int, timecodeScale int) []uint8 {
+	var content []uint8
+	scale := addIntAs4Bytes(nil, timecodeScale)
+	content = addEBMLElement(content, []uint8{0x2A, 0xD7, 0xB1}, scale)
+
+	duration := make([]uint8, 8)
+	rawDuration := float64(seconds) * 1e9 / float64(timecodeScale)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(rawDuration))
+	content = addEBMLElement(content, []uint8{0x44, 0x89}, duration)
+	return addEBMLElement(nil, []uint8{0x15, 0x49, 0xA9, 0x66}, content)
+}
+
+// CreateMkvData returns a minimal matroska/webm file: EBML header and a Segment
+// holding a Void element followed by the segment Info with the given duration.
+func CreateMkvData(seconds int, docType string, timecodeScale int) []uint8 {
+	data := createEBMLHeader(docType)
+	data = append(data, 0x18, 0x53, 0x80, 0x67)
+	data = append(data, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	data = addEBMLElement(data, []uint8{0xEC}, addPadding(nil, 4))
+	data = append(data, createInfo(seconds, timecodeScale)...)
+	return data
+}
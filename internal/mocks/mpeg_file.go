@@ -0,0 +1,68 @@
+package mocks
+
+// Timestamps are 33 bit values of the 90 kHz clock.
+// PTS layout (5 bytes): 0010 xxx1 | xxxxxxxx | xxxxxxx1 | xxxxxxxx | xxxxxxx1
+// MPEG-2 pack header SCR (6 bytes after 00 00 01 BA):
+// 01 xxx 1 xx | xxxxxxxx | xxxxx 1 xx | xxxxxxxx | xxxxx 1 xx | extension
+
+const (
+	tsPacketSize = 188
+	mpegClock    = 90000
+)
+
+func encodePTS(pts int64) []uint8 {
+	return []uint8{
+		uint8(0x20 | (pts>>29)&0x0E | 0x01),
+		uint8(pts >> 22),
+		uint8((pts>>14)&0xFE | 0x01),
+		uint8(pts >> 7),
+		uint8((pts<<1)&0xFE | 0x01),
+	}
+}
+
+func encodeSCR(scr int64) []uint8 {
+	return []uint8{
+		uint8(0x40 | (scr>>27)&0x38 | 0x04 | (scr>>28)&0x03),
+		uint8(scr >> 20),
+		uint8((scr>>12)&0xF8 | 0x04 | (scr>>13)&0x03),
+		uint8(scr >> 5),
+		uint8((scr<<3)&0xF8 | 0x04),
+		0x01,
+	}
+}
+
+func createTsPacket(pid int, pts int64) []uint8 {
+	packet := []uint8{0x47, uint8(0x40 | pid>>8), uint8(pid), 0x10}
+	// PES header: start code, stream id, length, flags, PTS only, header length 5
+	packet = append(packet, 0x00, 0x00, 0x01, 0xE0, 0x00, 0x00, 0x80, 0x80, 0x05)
+	packet = append(packet, encodePTS(pts)...)
+	for len(packet) < tsPacketSize {
+		packet = append(packet, 0xFF)
+	}
+	return packet
+}
+
+// CreateTsData returns a transport stream with one video PES packet per second
+// of the given duration, starting at startPts. An audio PID with an unrelated
+// PTS is interleaved to make sure only one PID is used for the estimation.
+func CreateTsData(seconds int, startPts int64) []uint8 {
+	var data []uint8
+	for i := 0; i <= seconds; i++ {
+		pts := (startPts + int64(i)*mpegClock) % (1 << 33)
+		data = append(data, createTsPacket(0x100, pts)...)
+		data = append(data, createTsPacket(0x101, 12345)...)
+	}
+	return data
+}
+
+// CreatePsData returns an MPEG-2 program stream with one pack header per second.
+func CreatePsData(seconds int, startScr int64) []uint8 {
+	var data []uint8
+	for i := 0; i <= seconds; i++ {
+		data = append(data, 0x00, 0x00, 0x01, 0xBA)
+		data = append(data, encodeSCR(startScr+int64(i)*mpegClock)...)
+		data = append(data, 0x01, 0x89, 0xC3, 0xF8)
+		data = addPadding(data, 32)
+	}
+	return data
+}
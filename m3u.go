@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const m3uHeader = "#EXTM3U"
+
+type m3uExporter struct{}
+
+func (m3uExporter) Name() string { return FormatM3u8 }
+
+func (m3uExporter) Extension() string { return ".m3u8" }
+
+// Export writes an extended M3U, with the play options as VLC option lines
+// for every entry
+func (m3uExporter) Export(content []MediaItem, options ExportOptions, w io.Writer) error {
+	paths, err := exportPaths(content, options.Base)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString(m3uHeader + "\n")
+	for i, item := range content {
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n", int(math.Round(item.Duration)), item.Name)
+		for _, option := range itemOptions(item, options.PlayOptions) {
+			fmt.Fprintf(&b, "#EXTVLCOPT:%s\n", option)
+		}
+		b.WriteString(paths[i] + "\n")
+	}
+	_, err = io.WriteString(w, b.String())
+	if err != nil {
+		return fmt.Errorf("Error writing m3u playlist: %w\n", err)
+	}
+	return nil
+}
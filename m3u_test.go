@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+)
+
+func TestM3UExporterAbsolute(t *testing.T) {
+	var buf bytes.Buffer
+	err := m3uExporter{}.Export(_createExportItems(), ExportOptions{PlayOptions: PlayOptions{Audio: true}}, &buf)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	expected := "#EXTM3U\n" +
+		"#EXTINF:65,a b.mp4\n/home/Music/rock/a b.mp4\n" +
+		"#EXTINF:30,c.mp4\n/home/Music/jazz/c.mp4\n"
+	assert.Equal(t, "Should write extended m3u", buf.String(), expected)
+}
+
+func TestM3UExporterRelativeWithOptions(t *testing.T) {
+	var buf bytes.Buffer
+	options := ExportOptions{PlayOptions: PlayOptions{StartTime: 10, StopTime: 20}, Base: "/home/Music/rock"}
+	err := m3uExporter{}.Export(_createExportItems(), options, &buf)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	expected := "#EXTM3U\n" +
+		"#EXTINF:65,a b.mp4\n#EXTVLCOPT:no-audio\n#EXTVLCOPT:start-time=10\n#EXTVLCOPT:stop-time=20\na b.mp4\n" +
+		"#EXTINF:30,c.mp4\n#EXTVLCOPT:no-audio\n#EXTVLCOPT:start-time=10\n#EXTVLCOPT:stop-time=20\n../jazz/c.mp4\n"
+	assert.Equal(t, "Should write relative paths and vlc options", buf.String(), expected)
+}
+
+func TestM3UExporterError(t *testing.T) {
+	err := m3uExporter{}.Export(_createExportItems(), ExportOptions{}, mocks.FakeWriter{})
+	assert.ErrorRaised(t, "Should raise write error", err, true)
+	err = m3uExporter{}.Export(_createExportItems(), ExportOptions{Base: "relative/base"}, &bytes.Buffer{})
+	assert.ErrorRaised(t, "Should raise error for unrelatable base", err, true)
+}
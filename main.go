@@ -4,11 +4,17 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 )
 
 func main() {
-	defer TimeTrack(time.Now(), "main")
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		mergeMain(os.Args[2:])
+		return
+	}
+	start := time.Now()
+	defer TimeTrack(start, "main")
 	params, err := getParams()
 	if err != nil {
 		log.Fatalf("Param validation error: %s\n", err)
@@ -24,26 +30,87 @@ func main() {
 		}
 		fmt.Printf("Extensions: %v\n", extensions)
 	}
-	content, summary, err := collectMediaContent(params.MediaPath, fsys, *params)
+	indexFile := params.getIndexFileName()
+	params.index, err = loadIndex(indexFile, params.MediaPath, params.rebuildIndex)
+	if err != nil {
+		log.Fatalf("Error during loading media index: %s\n", err)
+	}
+	rng := params.RandomizerOptions.newRand()
+	collectStart := time.Now()
+	content, summary, err := collectMediaContent(rng, params.MediaPath, fsys, *params)
 	if err != nil {
 		log.Fatalf("Error during getting files: %s\n", err)
 	}
-	randomizePlaylist(content, int(params.RandomizerOptions.Stabilizer))
-	playList := buildPlayList(content, params.PlayOptions)
-
-	outfile, err := createFile(params.FileName)
+	summary.addTiming("collect", TimeTrack(collectStart, "collect"))
+	if pruned := params.index.prune(fsys); pruned > 0 {
+		log.Printf("Removed %d missing files from media index\n", pruned)
+	}
+	err = params.index.save(indexFile)
 	if err != nil {
-		log.Fatalf("Error during creating file: %s\n", err)
+		log.Printf("Media index not saved: %s\n", err)
 	}
-	defer outfile.Close()
+	orderPlaylist(rng, content, params.RandomizerOptions)
+	playList := buildPlayList(content, params.PlayOptions)
+	playList.annotateSeed(params.RandomizerOptions.Seed)
 
-	err = writePlayList(playList, outfile)
+	exporter, _ := exporters.byName(params.Format)
+	err = exportPlayList(params.FileName, exporter, content, params)
 	if err != nil {
 		log.Fatalf("Error during writing playlist file: %s\n", err)
 	}
+	for _, fn := range params.ExtraOutputs {
+		exporter, _ := exporters.byExtension(filepath.Ext(fn))
+		err = exportPlayList(fn, exporter, content, params)
+		if err != nil {
+			log.Fatalf("Error during writing %s: %s\n", fn, err)
+		}
+		log.Printf("Extra output written to %s\n", fn)
+	}
+	summary.addTiming("total", time.Since(start))
 	// TODO: maybe make duration bucket summary optional too
 	summary.getData(os.Stdout)
+	if params.ScanOptions.ErrorPolicy == ErrorPolicySkipAndReport && len(summary.skipped) > 0 {
+		problemsFile, err := createFile(params.getProblemsFileName())
+		if err != nil {
+			log.Fatalf("Error during creating problems report: %s\n", err)
+		}
+		defer problemsFile.Close()
+		err = summary.writeProblems(problemsFile)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		log.Printf("Problems report written to %s\n", problemsFile.Name())
+	}
+	if params.reportFile != "" {
+		reportFile, err := createFile(params.reportFile)
+		if err != nil {
+			log.Fatalf("Error during creating report: %s\n", err)
+		}
+		defer reportFile.Close()
+		if params.isHTMLReport() {
+			err = writeHTMLReport(summary.report(), playList, reportFile)
+		} else {
+			err = writeReport(summary.report(), reportFile)
+		}
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		log.Printf("Report written to %s\n", reportFile.Name())
+	}
 	if params.playFlag {
 		playMixList(params.FileName, params.MarqueeOptions)
 	}
 }
+
+func mergeMain(args []string) {
+	params, err := parseMergeArgs(args, os.Stderr)
+	if err != nil {
+		log.Fatalf("Param validation error: %s\n", err)
+	}
+	tracks, removed, err := runMerge(params)
+	if err != nil {
+		log.Fatalf("Error during merging playlists: %s\n", err)
+	}
+	log.Printf("Merged %d playlists into %s: %d tracks, %d duplicates removed, seed %d\n",
+		len(params.inputs), params.output, tracks, removed, params.randomizer.Seed)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+)
+
+const (
+	MergeConcat          = "concat"
+	MergeInterleave      = "interleave"
+	MergeShuffleTogether = "shuffle-together"
+)
+
+type mergeParams struct {
+	inputs     []string
+	output     string
+	strategy   string
+	stabilizer int
+	randomizer RandomizerOptions
+}
+
+// parseMergeArgs reads the arguments of the merge command. Flags may come
+// before, between or after the input playlists.
+func parseMergeArgs(args []string, output io.Writer) (*mergeParams, error) {
+	p := &mergeParams{}
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: playmix merge [flags] a.xspf b.xspf ...")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&p.output, "o", "", "File to write the merged playlist to, its extension selects the format")
+	fs.StringVar(&p.strategy, "strategy", MergeConcat, "How playlists are combined: concat, interleave or shuffle-together")
+	fs.IntVar(&p.stabilizer, "stabilizer", 0, "Keeps every n-th track in place when shuffling together")
+	seed := fs.Int64("seed", 0, "Seed for shuffle-together (defaults to a random seed)")
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		p.inputs = append(p.inputs, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	err := p.validate()
+	if err != nil {
+		return nil, err
+	}
+	p.randomizer.setSeed(*seed)
+	return p, nil
+}
+
+func (p *mergeParams) validate() error {
+	if len(p.inputs) < 2 {
+		return fmt.Errorf("Merge needs at least two playlists, got %d\n", len(p.inputs))
+	}
+	if p.output == "" {
+		return fmt.Errorf("Output file should be set with -o\n")
+	}
+	e, found := exporters.byExtension(filepath.Ext(p.output))
+	if !found {
+		return fmt.Errorf("Output %s should have the extension of one of %v\n", p.output, exporters.names())
+	}
+	if isTrackListFormat(e.Name()) {
+		return fmt.Errorf("Output %s should be a playlist, track lists need paths relative to a media folder\n", p.output)
+	}
+	switch p.strategy {
+	case MergeConcat, MergeInterleave, MergeShuffleTogether:
+	default:
+		return fmt.Errorf("Strategy should be one of %s, %s, %s, got %s\n", MergeConcat, MergeInterleave, MergeShuffleTogether, p.strategy)
+	}
+	if p.stabilizer < 0 {
+		return fmt.Errorf("Stabilizer should not be negative, got %d\n", p.stabilizer)
+	}
+	return nil
+}
+
+// locationKey identifies a track for deduplication; file locations are
+// compared by their decoded path so differently escaped URLs still match
+func locationKey(item MediaItem) string {
+	if item.AbsPath != "" {
+		return item.AbsPath
+	}
+	return item.Location
+}
+
+// dedupByLocation keeps the first occurrence of every location
+func dedupByLocation(items []MediaItem) ([]MediaItem, int) {
+	seen := map[string]bool{}
+	var unique []MediaItem
+	for _, item := range items {
+		key := locationKey(item)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, item)
+	}
+	return unique, len(items) - len(unique)
+}
+
+// mergePlayLists combines the tracks of the playlists with the strategy,
+// drops duplicate locations and renumbers the ids in the merged order
+func mergePlayLists(rng *rand.Rand, lists [][]MediaItem, strategy string, stabilizer int) ([]MediaItem, int) {
+	var merged []MediaItem
+	switch strategy {
+	case MergeInterleave:
+		for i := 0; ; i++ {
+			added := false
+			for _, list := range lists {
+				if i < len(list) {
+					merged = append(merged, list[i])
+					added = true
+				}
+			}
+			if !added {
+				break
+			}
+		}
+	default:
+		for _, list := range lists {
+			merged = append(merged, list...)
+		}
+	}
+	merged, removed := dedupByLocation(merged)
+	if strategy == MergeShuffleTogether {
+		randomizePlaylist(rng, merged, stabilizer)
+	}
+	for i := range merged {
+		merged[i].Id = i
+	}
+	return merged, removed
+}
+
+// runMerge loads the input playlists, merges them and writes the result
+func runMerge(p *mergeParams) (int, int, error) {
+	var lists [][]MediaItem
+	for _, fn := range p.inputs {
+		_, items, err := loadPlayList(fn)
+		if err != nil {
+			return 0, 0, err
+		}
+		lists = append(lists, items)
+	}
+	merged, removed := mergePlayLists(p.randomizer.newRand(), lists, p.strategy, p.stabilizer)
+	// relative locations were resolved against their own playlist's folder
+	for i := range merged {
+		if merged[i].AbsPath != "" {
+			merged[i].Location = getUrlEncodedPath(merged[i].AbsPath)
+		}
+	}
+	exporter, _ := exporters.byExtension(filepath.Ext(p.output))
+	// options come from the tracks themselves, so no play options are added
+	params := &Params{PlayOptions: PlayOptions{Audio: true}, RandomizerOptions: p.randomizer}
+	err := exportPlayList(p.output, exporter, merged, params)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(merged), removed, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"playmix/internal/assert"
+	"testing"
+)
+
+func _createMergeLists() [][]MediaItem {
+	return [][]MediaItem{
+		{{AbsPath: "/m/a.mp4", Id: 0}, {AbsPath: "/m/b.mp4", Id: 1}, {AbsPath: "/m/c.mp4", Id: 2}},
+		{{AbsPath: "/m/d.mp4", Id: 0}, {AbsPath: "/m/a.mp4", Id: 1}},
+	}
+}
+
+func _mergedPaths(items []MediaItem) []string {
+	var paths []string
+	for _, item := range items {
+		paths = append(paths, item.AbsPath)
+	}
+	return paths
+}
+
+func TestParseMergeArgs(t *testing.T) {
+	p, err := parseMergeArgs([]string{"a.xspf", "-strategy", "interleave", "b.xspf", "-o", "out.m3u8", "c.xspf"}, io.Discard)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.EqualSlice(t, "Should collect inputs around flags", p.inputs, []string{"a.xspf", "b.xspf", "c.xspf"})
+	assert.Equal(t, "Should read output", p.output, "out.m3u8")
+	assert.Equal(t, "Should read strategy", p.strategy, MergeInterleave)
+	assert.Equal(t, "Should set a seed", p.randomizer.Seed != 0, true)
+
+	p, _ = parseMergeArgs([]string{"-seed", "7", "a.xspf", "b.xspf", "-o", "out.xspf"}, io.Discard)
+	assert.Equal(t, "Should default to concat", p.strategy, MergeConcat)
+	assert.Equal(t, "Should keep seed", p.randomizer.Seed, 7)
+}
+
+func TestParseMergeArgsErrors(t *testing.T) {
+	tests := [][]string{
+		{"a.xspf", "-o", "out.xspf"},
+		{"a.xspf", "b.xspf"},
+		{"a.xspf", "b.xspf", "-o", "out.txt"},
+		{"a.xspf", "b.xspf", "-o", "out.csv"},
+		{"a.xspf", "b.xspf", "-o", "out.xspf", "-strategy", "zip"},
+		{"a.xspf", "b.xspf", "-o", "out.xspf", "-stabilizer", "-1"},
+		{"a.xspf", "b.xspf", "-unknown"},
+	}
+	for _, args := range tests {
+		_, err := parseMergeArgs(args, io.Discard)
+		assert.ErrorRaised(t, "Should raise error", err, true)
+	}
+}
+
+func TestMergePlayListsConcat(t *testing.T) {
+	merged, removed := mergePlayLists(_newRand(), _createMergeLists(), MergeConcat, 0)
+	assert.EqualSlice(t, "Should append lists", _mergedPaths(merged), []string{"/m/a.mp4", "/m/b.mp4", "/m/c.mp4", "/m/d.mp4"})
+	assert.Equal(t, "Should count duplicates", removed, 1)
+	assert.Equal(t, "Should renumber ids", merged[3].Id, 3)
+}
+
+func TestMergePlayListsInterleave(t *testing.T) {
+	merged, removed := mergePlayLists(_newRand(), _createMergeLists(), MergeInterleave, 0)
+	assert.EqualSlice(t, "Should alternate lists", _mergedPaths(merged), []string{"/m/a.mp4", "/m/d.mp4", "/m/b.mp4", "/m/c.mp4"})
+	assert.Equal(t, "Should count duplicates", removed, 1)
+}
+
+func TestMergePlayListsShuffleTogether(t *testing.T) {
+	merged, removed := mergePlayLists(_newRand(), _createMergeLists(), MergeShuffleTogether, 0)
+	again, _ := mergePlayLists(_newRand(), _createMergeLists(), MergeShuffleTogether, 0)
+	assert.Equal(t, "Should count duplicates", removed, 1)
+	assert.Equal(t, "Should keep unique tracks", len(merged), 4)
+	assert.EqualSlice(t, "Same seed should shuffle the same", _mergedPaths(merged), _mergedPaths(again))
+	for i, item := range merged {
+		assert.Equal(t, "Should renumber ids after shuffle", item.Id, i)
+	}
+}
+
+func TestDedupByLocationStreams(t *testing.T) {
+	items := []MediaItem{{Location: "http://a/s"}, {Location: "http://a/s"}, {Location: "http://b/s"}}
+	unique, removed := dedupByLocation(items)
+	assert.Equal(t, "Should compare streams by location", len(unique), 2)
+	assert.Equal(t, "Should count duplicates", removed, 1)
+}
+
+func TestRunMerge(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.xspf")
+	f, err := os.Create(first)
+	assert.ErrorRaised(t, "Should create playlist", err, false)
+	err = xspfExporter{}.Export(_createExportItems(), ExportOptions{PlayOptions: PlayOptions{Audio: true}, Seed: 1}, f)
+	f.Close()
+	assert.ErrorRaised(t, "Should write playlist", err, false)
+
+	p := &mergeParams{
+		inputs:   []string{first, filepath.Join("testdata", "vlc.xspf")},
+		output:   filepath.Join(dir, "out.xspf"),
+		strategy: MergeConcat,
+	}
+	tracks, removed, err := runMerge(p)
+	assert.ErrorRaised(t, "Should merge playlists", err, false)
+	assert.Equal(t, "Should drop the shared track", removed, 1)
+	assert.Equal(t, "Should write unique tracks", tracks, 4)
+
+	_, items, err := loadPlayList(p.output)
+	assert.ErrorRaised(t, "Should read merged playlist", err, false)
+	assert.Equal(t, "Should read merged tracks", len(items), 4)
+	assert.Equal(t, "Should renumber vlc:id", items[3].Id, 3)
+	assert.Equal(t, "Should keep durations in seconds", items[2].Duration, 30)
+	testdata, _ := filepath.Abs("testdata")
+	assert.Equal(t, "Should keep relative location pointing at its file", items[2].AbsPath, filepath.Join(testdata, "jazz", "c.mp4"))
+	assert.Equal(t, "Should keep stream location", items[3].Location, "http://example.com/stream.mp4")
+
+	p.inputs = []string{first, filepath.Join(dir, "missing.xspf")}
+	_, _, err = runMerge(p)
+	assert.ErrorRaised(t, "Should raise error for missing input", err, true)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// EBML element ids used to locate the segment duration.
+// See https://www.matroska.org/technical/elements.html
+const (
+	ebmlHeaderID    = 0x1A45DFA3
+	segmentID       = 0x18538067
+	infoID          = 0x1549A966
+	timecodeScaleID = 0x2AD7B1
+	mkvDurationID   = 0x4489
+	clusterID       = 0x1F43B675
+
+	defaultTimecodeScale = 1000000 // nanoseconds
+	unknownSize          = -1
+)
+
+type ebmlReader struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// readVint reads a variable size integer at off, returning its value and length.
+// When keepMarker is true the length marker bit is kept (used for element ids).
+func (e ebmlReader) readVint(off int64, keepMarker bool) (uint64, int, error) {
+	first := make([]byte, 1)
+	if _, err := e.r.ReadAt(first, off); err != nil {
+		return 0, 0, err
+	}
+	length := 1
+	for mask := byte(0x80); first[0]&mask == 0; mask >>= 1 {
+		length++
+		if length > 8 {
+			return 0, 0, fmt.Errorf("Invalid EBML variable size integer at offset %d", off)
+		}
+	}
+	buf := make([]byte, length)
+	if _, err := e.r.ReadAt(buf, off); err != nil {
+		return 0, 0, err
+	}
+	if !keepMarker {
+		buf[0] &= byte(0xFF >> length)
+	}
+	var value uint64
+	for _, b := range buf {
+		value = value<<8 | uint64(b)
+	}
+	return value, length, nil
+}
+
+// readElementHeader returns the id and data size of the element at off together
+// with the offset where its data starts. Data size is unknownSize for streamed elements.
+func (e ebmlReader) readElementHeader(off int64) (id uint64, dataSize int64, dataStart int64, err error) {
+	id, idLen, err := e.readVint(off, true)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	size, sizeLen, err := e.readVint(off+int64(idLen), false)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	dataSize = int64(size)
+	if size == uint64(1)<<(7*sizeLen)-1 {
+		dataSize = unknownSize
+	}
+	return id, dataSize, off + int64(idLen+sizeLen), nil
+}
+
+func (e ebmlReader) readData(off, n int64) ([]byte, error) {
+	if n < 0 || n > 8 {
+		return nil, fmt.Errorf("Unexpected EBML element size %d at offset %d", n, off)
+	}
+	buf := make([]byte, n)
+	if _, err := e.r.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (e ebmlReader) readUint(off, n int64) (uint64, error) {
+	buf, err := e.readData(off, n)
+	if err != nil {
+		return 0, err
+	}
+	var value uint64
+	for _, b := range buf {
+		value = value<<8 | uint64(b)
+	}
+	return value, nil
+}
+
+func (e ebmlReader) readFloat(off, n int64) (float64, error) {
+	buf, err := e.readData(off, n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 0:
+		return 0, nil
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(buf))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(buf)), nil
+	}
+	return 0, fmt.Errorf("Invalid EBML float size %d at offset %d", n, off)
+}
+
+// findChild scans the children of a master element between start and end
+// and returns the data offset and size of the first child with the given id.
+func (e ebmlReader) findChild(start, end int64, id uint64) (int64, int64, error) {
+	for off := start; off < end; {
+		childID, dataSize, dataStart, err := e.readElementHeader(off)
+		if err != nil {
+			return 0, 0, err
+		}
+		if childID == id {
+			return dataStart, dataSize, nil
+		}
+		if dataSize == unknownSize || childID == clusterID {
+			break
+		}
+		off = dataStart + dataSize
+	}
+	return 0, 0, fmt.Errorf("EBML element %X not found", id)
+}
+
+func (e ebmlReader) getDuration() (float64, error) {
+	id, headerSize, headerStart, err := e.readElementHeader(0)
+	if err != nil {
+		return 0, err
+	}
+	if id != ebmlHeaderID {
+		return 0, fmt.Errorf("EBML header not found. Is this mkv/webm?")
+	}
+	segmentStart, segmentSize, err := e.findChild(headerStart+headerSize, e.size, segmentID)
+	if err != nil {
+		return 0, err
+	}
+	segmentEnd := e.size
+	if segmentSize != unknownSize && segmentStart+segmentSize < segmentEnd {
+		segmentEnd = segmentStart + segmentSize
+	}
+	infoStart, infoSize, err := e.findChild(segmentStart, segmentEnd, infoID)
+	if err != nil {
+		return 0, err
+	}
+	if infoSize == unknownSize {
+		return 0, fmt.Errorf("Segment info has unknown size")
+	}
+	infoEnd := infoStart + infoSize
+
+	scale := uint64(defaultTimecodeScale)
+	scaleStart, scaleSize, err := e.findChild(infoStart, infoEnd, timecodeScaleID)
+	if err == nil {
+		scale, err = e.readUint(scaleStart, scaleSize)
+		if err != nil {
+			return 0, err
+		}
+	}
+	durationStart, durationSize, err := e.findChild(infoStart, infoEnd, mkvDurationID)
+	if err != nil {
+		return 0, fmt.Errorf("Duration not found in segment info")
+	}
+	rawDuration, err := e.readFloat(durationStart, durationSize)
+	if err != nil {
+		return 0, err
+	}
+	return rawDuration * float64(scale) / 1e9, nil
+}
+
+func getMkvDuration(r io.ReaderAt, size int64) (float64, error) {
+	reader := ebmlReader{r: r, size: size}
+	return reader.getDuration()
+}
+
+type mkvProber struct{}
+
+func (mkvProber) Name() string { return "matroska" }
+
+func (mkvProber) Extensions() []string { return []string{".mkv", ".webm"} }
+
+func (mkvProber) Match(header []byte) bool {
+	return bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3})
+}
+
+func (mkvProber) Probe(r io.ReaderAt, size int64) (MediaInfo, error) {
+	duration, err := getMkvDuration(r, size)
+	return MediaInfo{Duration: duration}, err
+}
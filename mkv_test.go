@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestGetMkvDuration(t *testing.T) {
+	data := mocks.CreateMkvData(90, "matroska", 1000000)
+	duration, err := getMkvDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should be 90 seconds", duration, 90)
+}
+
+func TestGetMkvDurationCustomTimecodeScale(t *testing.T) {
+	data := mocks.CreateMkvData(45, "webm", 1000)
+	duration, err := getMkvDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should be 45 seconds", duration, 45)
+}
+
+func TestGetMkvDurationNoEBMLHeader(t *testing.T) {
+	data := mocks.CreateData(60)
+	_, err := getMkvDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should raise error for mp4 data", err, true)
+}
+
+func TestGetMkvDurationTruncated(t *testing.T) {
+	data := mocks.CreateMkvData(90, "matroska", 1000000)
+	data = data[:len(data)-6]
+	_, err := getMkvDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should raise error for truncated file", err, true)
+}
+
+func TestReadVint(t *testing.T) {
+	tests := []struct {
+		data       []byte
+		keepMarker bool
+		value      uint64
+		length     int
+	}{
+		{[]byte{0x81}, false, 1, 1},
+		{[]byte{0x40, 0x02}, false, 2, 2},
+		{[]byte{0x1A, 0x45, 0xDF, 0xA3}, true, ebmlHeaderID, 4},
+		{[]byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, false, 1<<56 - 1, 8},
+	}
+	for _, tt := range tests {
+		e := ebmlReader{r: bytes.NewReader(tt.data), size: int64(len(tt.data))}
+		value, length, err := e.readVint(0, tt.keepMarker)
+		assert.ErrorRaised(t, "Should not raise error", err, false)
+		assert.Equal(t, "Should read value", value, tt.value)
+		assert.Equal(t, "Should read length", length, tt.length)
+	}
+}
+
+func TestReadVintInvalid(t *testing.T) {
+	e := ebmlReader{r: bytes.NewReader([]byte{0x00, 0x01}), size: 2}
+	_, _, err := e.readVint(0, false)
+	assert.ErrorRaised(t, "Should raise error for zero first byte", err, true)
+}
+
+func TestGetDurationMkv(t *testing.T) {
+	fn := "track.webm"
+	f := fstest.MapFS{
+		fn: {
+			Data:    mocks.CreateMkvData(120, "webm", 1000000),
+			Mode:    0755,
+			ModTime: time.Now(),
+		},
+	}
+	duration, err := getDuration(f, fn)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should be 120 seconds", duration, 120)
+}
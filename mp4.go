@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alfg/mp4"
+	"github.com/alfg/mp4/atom"
+)
+
+// mp4Epoch is the origin of the mvhd creation and modification times
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// boxes that can open an mp4/mov file
+var mp4TopLevelBoxes = []string{"ftyp", "moov", "mdat", "free", "skip", "wide"}
+
+func getMp4Duration(readerAt io.ReaderAt, size int64) (float64, error) {
+	info, err := getMp4Info(readerAt, size)
+	return info.Duration, err
+}
+
+func getMp4Info(readerAt io.ReaderAt, size int64) (MediaInfo, error) {
+	mp4, err := mp4.OpenFromReader(readerAt, size)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	if mp4.Moov == nil || mp4.Moov.Mvhd == nil {
+		return MediaInfo{}, fmt.Errorf("Moov box not found. Is this mp4?")
+	}
+	rawDuration := float64(mp4.Moov.Mvhd.Duration)
+	timeScale := float64(mp4.Moov.Mvhd.Timescale)
+
+	info := MediaInfo{Duration: rawDuration / timeScale, CreationTime: mvhdCreationTime(mp4.Moov.Mvhd)}
+	for _, trak := range mp4.Moov.Traks {
+		addTrackInfo(&info, trak)
+	}
+	return info, nil
+}
+
+// mvhdCreationTime reads the creation time the parser skips: seconds since
+// 1904-01-01 UTC, 32 bit in version 0 boxes and 64 bit in version 1 boxes.
+func mvhdCreationTime(mvhd *atom.MvhdBox) time.Time {
+	data := mvhd.ReadBoxData()
+	var seconds uint64
+	switch {
+	case len(data) >= 12 && data[0] == 1:
+		seconds = binary.BigEndian.Uint64(data[4:12])
+	case len(data) >= 8 && data[0] == 0:
+		seconds = uint64(binary.BigEndian.Uint32(data[4:8]))
+	}
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return mp4Epoch.Add(time.Duration(seconds) * time.Second)
+}
+
+// addTrackInfo fills the track details of info from a trak box. Only the first
+// video track is used for resolution, codec and frame rate.
+func addTrackInfo(info *MediaInfo, trak *atom.TrakBox) {
+	if trak.Mdia == nil || trak.Mdia.Hdlr == nil {
+		return
+	}
+	switch trak.Mdia.Hdlr.Handler {
+	case "soun":
+		info.HasAudio = true
+	case "vide":
+		if info.HasVideo {
+			return
+		}
+		info.HasVideo = true
+		if trak.Tkhd != nil {
+			info.Width = int(trak.Tkhd.GetWidth())
+			info.Height = int(trak.Tkhd.GetHeight())
+		}
+		if trak.Mdia.Minf == nil || trak.Mdia.Minf.Stbl == nil {
+			return
+		}
+		stbl := trak.Mdia.Minf.Stbl
+		if stbl.Stsd != nil {
+			info.Codec = sampleEntryCodec(stbl.Stsd)
+		}
+		if stbl.Stts != nil && trak.Mdia.Mdhd != nil {
+			info.FrameRate = frameRate(stbl.Stts, trak.Mdia.Mdhd)
+		}
+	}
+}
+
+// sampleEntryCodec returns the fourcc of the first sample entry:
+// stsd header, version/flags (4), entry count (4), entry size (4), fourcc (4).
+func sampleEntryCodec(stsd *atom.StsdBox) string {
+	fourcc := stsd.Reader.ReadBytesAt(4, stsd.Start+atom.BoxHeaderSize+12)
+	if len(fourcc) != 4 {
+		return ""
+	}
+	return string(fourcc)
+}
+
+func frameRate(stts *atom.SttsBox, mdhd *atom.MdhdBox) float64 {
+	if mdhd.Timescale == 0 || mdhd.Duration == 0 {
+		return 0
+	}
+	var samples uint64
+	for _, count := range stts.SampleCounts {
+		samples += uint64(count)
+	}
+	seconds := float64(mdhd.Duration) / float64(mdhd.Timescale)
+	return float64(samples) / seconds
+}
+
+type mp4Prober struct{}
+
+func (mp4Prober) Name() string { return "mp4" }
+
+func (mp4Prober) Extensions() []string { return []string{".mp4"} }
+
+func (mp4Prober) Match(header []byte) bool {
+	if len(header) < 8 {
+		return false
+	}
+	for _, box := range mp4TopLevelBoxes {
+		if string(header[4:8]) == box {
+			return true
+		}
+	}
+	return false
+}
+
+func (mp4Prober) Probe(r io.ReaderAt, size int64) (MediaInfo, error) {
+	return getMp4Info(r, size)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+	"time"
+)
+
+func TestGetMp4Info(t *testing.T) {
+	data := mocks.CreateDataWithTracks(12, 1920, 1080, "hvc1", 25, true)
+	info, err := getMp4Info(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should read duration", info.Duration, 12)
+	assert.Equal(t, "Should read width", info.Width, 1920)
+	assert.Equal(t, "Should read height", info.Height, 1080)
+	assert.Equal(t, "Should read codec", info.Codec, "hvc1")
+	assert.Equal(t, "Should compute frame rate", info.FrameRate, 25)
+	assert.Equal(t, "Should find video", info.HasVideo, true)
+	assert.Equal(t, "Should find audio", info.HasAudio, true)
+}
+
+func TestGetMp4InfoNoAudio(t *testing.T) {
+	data := mocks.CreateDataWithTracks(5, 640, 360, "avc1", 30, false)
+	info, err := getMp4Info(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should read codec", info.Codec, "avc1")
+	assert.Equal(t, "Should compute frame rate", info.FrameRate, 30)
+	assert.Equal(t, "Should not find audio", info.HasAudio, false)
+	assert.Equal(t, "Should have track info", info.hasTrackInfo(), true)
+}
+
+func TestGetMp4InfoWithoutTracks(t *testing.T) {
+	data := mocks.CreateData(10)
+	info, err := getMp4Info(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should read duration", info.Duration, 10)
+	assert.Equal(t, "Should not have track info", info.hasTrackInfo(), false)
+}
+
+func TestGetMp4InfoCreationTime(t *testing.T) {
+	created := time.Date(2023, 6, 16, 22, 13, 39, 0, time.UTC)
+	data := mocks.CreateDataWithCreationTime(10, created)
+	info, err := getMp4Info(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should read creation time", info.CreationTime, created)
+
+	data = mocks.CreateData(10)
+	info, _ = getMp4Info(bytes.NewReader(data), int64(len(data)))
+	assert.Equal(t, "Should leave unset creation time zero", info.CreationTime.IsZero(), true)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// MPEG durations are estimated from the 90 kHz clock: the PTS of the first
+// and last PES packets for transport streams, the SCR of the first and last
+// pack headers for program streams.
+const (
+	mpegClock       = 90000
+	mpegClockWrap   = 1 << 33
+	mpegScanWindow  = 1 << 20
+	tsPacketSize    = 188
+	m2tsPacketSize  = 192
+	tsSyncByte      = 0x47
+	tsSyncCheckRuns = 3
+)
+
+var packStartCode = []byte{0x00, 0x00, 0x01, 0xBA}
+
+type mpegReader struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// readWindow reads up to mpegScanWindow bytes starting at off.
+func (m mpegReader) readWindow(off int64) ([]byte, error) {
+	if off < 0 {
+		off = 0
+	}
+	n := int64(mpegScanWindow)
+	if off+n > m.size {
+		n = m.size - off
+	}
+	buf := make([]byte, n)
+	read, err := m.r.ReadAt(buf, off)
+	if read == 0 && err != nil {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// decodeTimestamp decodes the 33 bit PTS/DTS (and MPEG-1 SCR) layout:
+// 4 bit prefix, 3 bits, marker, 15 bits, marker, 15 bits, marker.
+func decodeTimestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 | int64(b[1])<<22 | int64(b[2]>>1)<<15 | int64(b[3])<<7 | int64(b[4]>>1)
+}
+
+// decodeSCR decodes the SCR base of a pack header, b starting after the start code.
+func decodeSCR(b []byte) (int64, bool) {
+	switch {
+	case b[0]&0xC0 == 0x40: // MPEG-2
+		return int64(b[0]>>3&0x07)<<30 | int64(b[0]&0x03)<<28 | int64(b[1])<<20 |
+			int64(b[2]>>3)<<15 | int64(b[2]&0x03)<<13 | int64(b[3])<<5 | int64(b[4]>>3), true
+	case b[0]&0xF0 == 0x20: // MPEG-1
+		return decodeTimestamp(b), true
+	}
+	return 0, false
+}
+
+// tsLayout returns the packet size and the offset of the first sync byte.
+// At least two sync bytes a packet apart must be found, as a single 0x47 byte
+// is too common to tell a transport stream.
+func tsLayout(window []byte) (int, int, bool) {
+	for _, layout := range [][2]int{{tsPacketSize, 0}, {m2tsPacketSize, 4}} {
+		packetSize, syncOffset := layout[0], layout[1]
+		ok, found := true, 0
+		for i := 0; i < tsSyncCheckRuns && ok; i++ {
+			pos := syncOffset + i*packetSize
+			if pos >= len(window) {
+				break
+			}
+			ok = window[pos] == tsSyncByte
+			if ok {
+				found++
+			}
+		}
+		if ok && found >= 2 {
+			return packetSize, syncOffset, true
+		}
+	}
+	return 0, 0, false
+}
+
+// packetPTS returns the PID and PTS of a transport packet starting a PES packet with a PTS.
+func packetPTS(packet []byte) (int, int64, bool) {
+	if len(packet) < tsPacketSize || packet[0] != tsSyncByte || packet[1]&0x40 == 0 {
+		return 0, 0, false
+	}
+	pid := int(packet[1]&0x1F)<<8 | int(packet[2])
+	adaptation := packet[3] >> 4 & 0x03
+	payload := 4
+	if adaptation&0x02 != 0 {
+		payload += 1 + int(packet[4])
+	}
+	if adaptation&0x01 == 0 || payload+14 > len(packet) {
+		return 0, 0, false
+	}
+	pes := packet[payload:]
+	if pes[0] != 0 || pes[1] != 0 || pes[2] != 1 || pes[7]&0x80 == 0 {
+		return 0, 0, false
+	}
+	return pid, decodeTimestamp(pes[9:14]), true
+}
+
+// scanPTS returns the first and last PTS found for pid in the window,
+// pid -1 matches the first PID carrying a PTS.
+func scanPTS(window []byte, packetSize, syncOffset, pid int) (int, int64, int64, bool) {
+	var first, last int64
+	found := false
+	for pos := syncOffset; pos+tsPacketSize <= len(window); pos += packetSize {
+		packetPid, pts, ok := packetPTS(window[pos : pos+tsPacketSize])
+		if !ok || (pid != -1 && packetPid != pid) {
+			continue
+		}
+		pid = packetPid
+		if !found {
+			first = pts
+			found = true
+		}
+		last = pts
+	}
+	return pid, first, last, found
+}
+
+func (m mpegReader) tsDuration(head []byte, packetSize, syncOffset int) (float64, error) {
+	pid, first, _, found := scanPTS(head, packetSize, syncOffset, -1)
+	if !found {
+		return 0, fmt.Errorf("No PTS found at the start of transport stream")
+	}
+	tailStart := m.size - mpegScanWindow
+	if tailStart < 0 {
+		tailStart = 0
+	}
+	tailStart -= tailStart % int64(packetSize)
+	tail, err := m.readWindow(tailStart)
+	if err != nil {
+		return 0, err
+	}
+	_, _, last, found := scanPTS(tail, packetSize, syncOffset, pid)
+	if !found {
+		return 0, fmt.Errorf("No PTS found at the end of transport stream")
+	}
+	return clockDuration(first, last), nil
+}
+
+func (m mpegReader) psDuration(head []byte) (float64, error) {
+	first, found := firstSCR(head)
+	if !found {
+		return 0, fmt.Errorf("No pack header found at the start of program stream")
+	}
+	tail, err := m.readWindow(m.size - mpegScanWindow)
+	if err != nil {
+		return 0, err
+	}
+	last, found := lastSCR(tail)
+	if !found {
+		return 0, fmt.Errorf("No pack header found at the end of program stream")
+	}
+	return clockDuration(first, last), nil
+}
+
+func firstSCR(window []byte) (int64, bool) {
+	for pos := 0; ; {
+		idx := bytes.Index(window[pos:], packStartCode)
+		if idx < 0 {
+			return 0, false
+		}
+		pos += idx + len(packStartCode)
+		if pos+5 <= len(window) {
+			if scr, ok := decodeSCR(window[pos : pos+5]); ok {
+				return scr, true
+			}
+		}
+	}
+}
+
+func lastSCR(window []byte) (int64, bool) {
+	for end := len(window); ; {
+		idx := bytes.LastIndex(window[:end], packStartCode)
+		if idx < 0 {
+			return 0, false
+		}
+		pos := idx + len(packStartCode)
+		if pos+5 <= len(window) {
+			if scr, ok := decodeSCR(window[pos : pos+5]); ok {
+				return scr, true
+			}
+		}
+		end = idx
+	}
+}
+
+// clockDuration converts two 90 kHz timestamps to seconds, allowing one wrap of the 33 bit clock.
+func clockDuration(first, last int64) float64 {
+	diff := last - first
+	if diff < 0 {
+		diff += mpegClockWrap
+	}
+	return float64(diff) / mpegClock
+}
+
+func (m mpegReader) getDuration() (float64, error) {
+	head, err := m.readWindow(0)
+	if err != nil {
+		return 0, err
+	}
+	if packetSize, syncOffset, ok := tsLayout(head); ok {
+		return m.tsDuration(head, packetSize, syncOffset)
+	}
+	if bytes.HasPrefix(head, packStartCode) {
+		return m.psDuration(head)
+	}
+	return 0, fmt.Errorf("Neither transport nor program stream. Is this mpeg?")
+}
+
+func getMpegDuration(r io.ReaderAt, size int64) (float64, error) {
+	reader := mpegReader{r: r, size: size}
+	return reader.getDuration()
+}
+
+type mpegProber struct{}
+
+func (mpegProber) Name() string { return "mpeg" }
+
+func (mpegProber) Extensions() []string { return []string{".mpeg", ".mpg", ".ts", ".m2ts"} }
+
+func (mpegProber) Match(header []byte) bool {
+	_, _, isTs := tsLayout(header)
+	return isTs || bytes.HasPrefix(header, packStartCode)
+}
+
+func (mpegProber) Probe(r io.ReaderAt, size int64) (MediaInfo, error) {
+	duration, err := getMpegDuration(r, size)
+	return MediaInfo{Duration: duration}, err
+}
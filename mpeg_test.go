@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestGetMpegDurationTransportStream(t *testing.T) {
+	data := mocks.CreateTsData(30, 900000)
+	duration, err := getMpegDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should be 30 seconds", duration, 30)
+}
+
+func TestGetMpegDurationTransportStreamWrap(t *testing.T) {
+	data := mocks.CreateTsData(20, 1<<33-10*90000)
+	duration, err := getMpegDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should handle PTS wrap around", duration, 20)
+}
+
+func TestGetMpegDurationProgramStream(t *testing.T) {
+	data := mocks.CreatePsData(15, 45000)
+	duration, err := getMpegDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should be 15 seconds", duration, 15)
+}
+
+func TestGetMpegDurationUnknown(t *testing.T) {
+	data := mocks.CreateData(60)
+	_, err := getMpegDuration(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should raise error for mp4 data", err, true)
+}
+
+func TestMpegProberMatchShortFile(t *testing.T) {
+	short := make([]byte, 100)
+	short[0] = tsSyncByte
+	assert.Equal(t, "Should not take a lone sync byte for transport stream", mpegProber{}.Match(short), false)
+	_, err := getMpegDuration(bytes.NewReader(short), int64(len(short)))
+	assert.ErrorRaised(t, "Should raise error for a lone sync byte", err, true)
+
+	data := mocks.CreateTsData(30, 900000)
+	assert.Equal(t, "Should match transport stream", mpegProber{}.Match(data[:sniffSize]), true)
+}
+
+func TestDecodeTimestamp(t *testing.T) {
+	// 0x21 0x00 0x05 0xBF 0x21 encodes 90000 (one second)
+	got := decodeTimestamp([]byte{0x21, 0x00, 0x05, 0xBF, 0x21})
+	assert.Equal(t, "Should decode PTS", got, 90000)
+}
+
+func TestClockDuration(t *testing.T) {
+	assert.Equal(t, "Should convert clock ticks", clockDuration(0, 180000), 2)
+	assert.Equal(t, "Should handle wrap", clockDuration(1<<33-90000, 90000), 2)
+}
+
+func TestCollectMediaContentMpegDurationFilter(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		minDuration:       20,
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+	}
+	fsys := fstest.MapFS{
+		"long.ts": {
+			Data:    mocks.CreateTsData(40, 0),
+			Mode:    0755,
+			ModTime: modTime,
+		},
+		"short.mpeg": {
+			Data:    mocks.CreatePsData(10, 0),
+			Mode:    0755,
+			ModTime: modTime,
+		},
+		"clip.flv": {
+			Data:    mocks.CreateFlvData(25, 25000),
+			Mode:    0755,
+			ModTime: modTime,
+		},
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should select two files", len(items), 2)
+	assert.Equal(t, "Should scan three files", summary.totalScanned, 3)
+	assert.Equal(t, "Should allocate short file to 5-10 bucket", summary.dBucket.counts[1], 1)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// dirGroup holds the items of one folder in playing order
+type dirGroup struct {
+	dir   string
+	items []MediaItem
+}
+
+// groupByDir groups items by Dir, shuffling the items inside each group
+// and the order of the groups.
+func groupByDir(rng *rand.Rand, playlist []MediaItem) []dirGroup {
+	var groups []dirGroup
+	positions := map[string]int{}
+	for _, item := range playlist {
+		pos, found := positions[item.Dir]
+		if !found {
+			pos = len(groups)
+			positions[item.Dir] = pos
+			groups = append(groups, dirGroup{dir: item.Dir})
+		}
+		groups[pos].items = append(groups[pos].items, item)
+	}
+	for _, g := range groups {
+		rng.Shuffle(len(g.items), func(i, j int) {
+			g.items[i], g.items[j] = g.items[j], g.items[i]
+		})
+	}
+	rng.Shuffle(len(groups), func(i, j int) {
+		groups[i], groups[j] = groups[j], groups[i]
+	})
+	return groups
+}
+
+// roundRobin takes one item of each group in turn. Once the smaller groups
+// run out the remaining ones keep alternating.
+func roundRobin(groups []dirGroup) []MediaItem {
+	var ordered []MediaItem
+	for round := 0; ; round++ {
+		added := false
+		for _, g := range groups {
+			if round < len(g.items) {
+				ordered = append(ordered, g.items[round])
+				added = true
+			}
+		}
+		if !added {
+			return ordered
+		}
+	}
+}
+
+// weighted spreads the items of each group evenly over the whole playlist, so
+// a group holding half of the items plays every other slot. Item k of a group
+// of n items is placed at (k+offset)/n, the random offset keeping groups of
+// the same size from always playing in the same order.
+func weighted(rng *rand.Rand, groups []dirGroup) []MediaItem {
+	type slot struct {
+		position float64
+		item     MediaItem
+	}
+	var slots []slot
+	for _, g := range groups {
+		offset := rng.Float64()
+		for k, item := range g.items {
+			slots = append(slots, slot{position: (float64(k) + offset) / float64(len(g.items)), item: item})
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].position < slots[j].position
+	})
+	ordered := make([]MediaItem, len(slots))
+	for i, s := range slots {
+		ordered[i] = s.item
+	}
+	return ordered
+}
+
+// spread places the items so that items of the same folder are as far apart
+// as possible and, when the folder sizes allow it, at least minGap other items
+// sit between two items of the same folder. At each slot it takes the folder
+// with the most items left among those that played more than minGap slots
+// ago, which never runs into a dead end while the gap can be kept. When no
+// folder is allowed the one that played the longest ago is taken.
+func spread(groups []dirGroup, minGap int) []MediaItem {
+	var ordered []MediaItem
+	lastPlayed := make([]int, len(groups))
+	for i := range lastPlayed {
+		lastPlayed[i] = -minGap - 1
+	}
+	for {
+		next, fallback := -1, -1
+		for i, g := range groups {
+			if len(g.items) == 0 {
+				continue
+			}
+			if fallback == -1 || lastPlayed[i] < lastPlayed[fallback] {
+				fallback = i
+			}
+			if len(ordered)-lastPlayed[i] > minGap && (next == -1 || len(g.items) > len(groups[next].items)) {
+				next = i
+			}
+		}
+		if fallback == -1 {
+			return ordered
+		}
+		if next == -1 {
+			next = fallback
+		}
+		ordered = append(ordered, groups[next].items[0])
+		groups[next].items = groups[next].items[1:]
+		lastPlayed[next] = len(ordered) - 1
+	}
+}
+
+// orderPlaylist reorders the playlist in place according to the order option
+func orderPlaylist(rng *rand.Rand, playlist []MediaItem, options RandomizerOptions) {
+	switch options.Order {
+	case OrderRoundRobin:
+		copy(playlist, roundRobin(groupByDir(rng, playlist)))
+	case OrderWeighted:
+		copy(playlist, weighted(rng, groupByDir(rng, playlist)))
+	case OrderSpread:
+		copy(playlist, spread(groupByDir(rng, playlist), options.minGap()))
+	default:
+		randomizePlaylist(rng, playlist, int(options.Stabilizer))
+	}
+}
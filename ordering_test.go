@@ -0,0 +1,151 @@
+package main
+
+import (
+	"math/rand"
+	"playmix/internal/assert"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// _createFolderItems creates sizes[i] items in folder_i, grouped by folder
+func _createFolderItems(sizes ...int) []MediaItem {
+	var items []MediaItem
+	for f, size := range sizes {
+		for i := 0; i < size; i++ {
+			items = append(items, MediaItem{Name: "track_" + strconv.Itoa(i) + ".mp4", Dir: "folder_" + strconv.Itoa(f), Id: len(items)})
+		}
+	}
+	return items
+}
+
+func _getDirs(items []MediaItem) []string {
+	var dirs []string
+	for _, item := range items {
+		dirs = append(dirs, item.Dir)
+	}
+	return dirs
+}
+
+func _sameItems(t *testing.T, got, expected []MediaItem) {
+	ids := _getIndices(got)
+	slices.Sort(ids)
+	assert.EqualSlice(t, "Should keep every item once", ids, _getIndices(expected))
+}
+
+func TestGroupByDir(t *testing.T) {
+	items := _createFolderItems(3, 1, 2)
+	groups := groupByDir(_newRand(), items)
+	assert.Equal(t, "Should create one group per folder", len(groups), 3)
+	for _, g := range groups {
+		for _, item := range g.items {
+			assert.Equal(t, "Group should only hold its folder", item.Dir, g.dir)
+		}
+	}
+}
+
+func TestOrderPlaylistRoundRobin(t *testing.T) {
+	items := _createFolderItems(4, 4, 4)
+	original := slices.Clone(items)
+	orderPlaylist(_newRand(), items, RandomizerOptions{Order: OrderRoundRobin})
+	_sameItems(t, items, original)
+	dirs := _getDirs(items)
+	for round := 0; round < 4; round++ {
+		played := dirs[round*3 : round*3+3]
+		slices.Sort(played)
+		assert.EqualSlice(t, "Every folder should play once per round", played, []string{"folder_0", "folder_1", "folder_2"})
+	}
+}
+
+func TestOrderPlaylistRoundRobinUnevenFolders(t *testing.T) {
+	items := _createFolderItems(5, 1)
+	orderPlaylist(_newRand(), items, RandomizerOptions{Order: OrderRoundRobin})
+	dirs := _getDirs(items)
+	assert.NotEqualSlice(t, "Small folder should play within the first round", dirs[:2], []string{"folder_0", "folder_0"})
+	assert.EqualSlice(t, "Large folder should fill the rest", dirs[2:], []string{"folder_0", "folder_0", "folder_0", "folder_0"})
+}
+
+func TestOrderPlaylistWeighted(t *testing.T) {
+	items := _createFolderItems(20, 10, 10)
+	original := slices.Clone(items)
+	orderPlaylist(_newRand(), items, RandomizerOptions{Order: OrderWeighted})
+	_sameItems(t, items, original)
+	dirs := _getDirs(items)
+	// each quarter of the playlist should hold about a quarter of each folder
+	for q := 0; q < 4; q++ {
+		quarter := dirs[q*10 : q*10+10]
+		big := 0
+		for _, dir := range quarter {
+			if dir == "folder_0" {
+				big++
+			}
+		}
+		assert.Equal(t, "Large folder should fill half of each quarter", big >= 4 && big <= 6, true)
+	}
+}
+
+func TestOrderPlaylistDefaultShuffles(t *testing.T) {
+	items, indices := _createMediaItems(30)
+	orderPlaylist(_newRand(), items, RandomizerOptions{})
+	assert.NotEqualSlice(t, "Indices should be different", _getIndices(items), indices)
+}
+
+// _minDirGap returns the fewest items found between two items of the same folder
+func _minDirGap(items []MediaItem) int {
+	gap := len(items)
+	last := map[string]int{}
+	for i, item := range items {
+		if prev, found := last[item.Dir]; found {
+			gap = min(gap, i-prev-1)
+		}
+		last[item.Dir] = i
+	}
+	return gap
+}
+
+func TestOrderPlaylistSpreadKeepsMinGap(t *testing.T) {
+	tests := []struct {
+		sizes  []int
+		minGap int
+	}{
+		{[]int{10, 10, 10}, 2},
+		{[]int{10, 5, 5}, 1},
+		{[]int{7, 6, 3, 3, 2}, 2},
+		{[]int{4, 4, 4, 4, 4}, 4},
+		{[]int{25, 20, 20, 20, 15}, 3},
+	}
+	for _, tt := range tests {
+		for run := 0; run < 20; run++ {
+			items := _createFolderItems(tt.sizes...)
+			original := slices.Clone(items)
+			orderPlaylist(rand.New(rand.NewSource(int64(run))), items, RandomizerOptions{Order: OrderSpread, MinGap: tt.minGap})
+			_sameItems(t, items, original)
+			assert.Equal(t, "Should keep min gap of "+strconv.Itoa(tt.minGap), _minDirGap(items) >= tt.minGap, true)
+		}
+	}
+}
+
+func TestOrderPlaylistSpreadDefaultGap(t *testing.T) {
+	items := _createFolderItems(5, 5)
+	orderPlaylist(_newRand(), items, RandomizerOptions{Order: OrderSpread})
+	assert.Equal(t, "Should not play a folder back to back", _minDirGap(items), 1)
+}
+
+func TestOrderPlaylistSpreadDominantFolder(t *testing.T) {
+	// 7 items of one folder cannot keep a gap of 2 among 10 items,
+	// the small folders should still break up the large one
+	items := _createFolderItems(7, 2, 1)
+	orderPlaylist(_newRand(), items, RandomizerOptions{Order: OrderSpread, MinGap: 2})
+	dirs := _getDirs(items)
+	assert.Equal(t, "Should play every item", len(dirs), 10)
+	assert.Equal(t, "Should start with the dominant folder", dirs[0], "folder_0")
+	run := 0
+	for _, dir := range dirs[:6] {
+		if dir == "folder_0" {
+			run++
+			assert.Equal(t, "Should not play three in a row early on", run < 3, true)
+		} else {
+			run = 0
+		}
+	}
+}
@@ -8,39 +8,84 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 const (
-	playListExtension = ".xspf"
+	FormatXspf  = "xspf"
+	FormatM3u8  = "m3u8"
+	FormatPls   = "pls"
+	FormatAsx   = "asx"
+	FormatWpl   = "wpl"
+	FormatJsonl = "jsonl"
+	FormatCsv   = "csv"
+)
+
+const (
+	reportExtension = ".json"
+	htmlExtension   = ".html"
 )
 
 type Params struct {
 	extFlag           bool
 	playFlag          bool
+	rebuildIndex      bool
 	minDuration       int
 	maxDuration       int
 	fdate             time.Time
 	tdate             time.Time
 	optFile           string
+	reportFile        string
 	MediaPath         string
 	FileName          string
+	Format            string
+	RelativePaths     bool
+	ExtraOutputs      []string
 	MarqueeOptions    Marquee
 	PlayOptions       PlayOptions
 	RandomizerOptions RandomizerOptions
 	FilterOptions     FilterOptions
+	ScanOptions       ScanOptions
+	SummaryOptions    SummaryOptions
+	index             *MediaIndex
 }
 
+func (p *Params) setFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	if _, found := exporters.byName(format); !found {
+		return fmt.Errorf("Invalid format, should be one of %v, got %s\n", exporters.names(), format)
+	}
+	p.Format = format
+	return nil
+}
+
+// setFileName picks the format from the extension of the file name when it
+// has one, otherwise the extension of the format is added
 func (p *Params) setFileName(fn string) error {
 	if fn == "" {
-		p.FileName = "pl-test" + playListExtension
-	} else {
-		ext := filepath.Ext(fn)
-		if ext != "" {
-			return fmt.Errorf("File name should not have extension defined")
+		fn = "pl-test"
+	}
+	ext := filepath.Ext(fn)
+	if ext == "" {
+		if p.Format == "" {
+			p.Format = FormatXspf
 		}
-		p.FileName = fn + playListExtension
+		e, _ := exporters.byName(p.Format)
+		p.FileName = fn + e.Extension()
+		return nil
+	}
+	e, found := exporters.byExtension(ext)
+	if !found {
+		return fmt.Errorf("File name extension %s is not a known playlist format\n", ext)
+	}
+	if p.Format != "" && p.Format != e.Name() {
+		return fmt.Errorf("File name extension %s does not match format %s\n", ext, p.Format)
 	}
+	p.Format = e.Name()
+	p.FileName = fn
 	return nil
 }
 
@@ -72,26 +117,52 @@ func (p *Params) parseOptFile(fsys fs.FS, fn string) error {
 	if err != nil {
 		return fmt.Errorf("Error unmarshalling options file: %s", err)
 	}
+	p.optFile = fn
 	p.MediaPath = opt.MediaPath
 	p.MarqueeOptions = opt.Marquee
 	p.PlayOptions = opt.PlayOptions
 	p.RandomizerOptions = opt.RandomizerOptions
 	p.FilterOptions = opt.FilterOptions
+	p.ScanOptions = opt.ScanOptions
+	p.SummaryOptions = opt.SummaryOptions
+	p.RelativePaths = opt.RelativePaths
+	p.ExtraOutputs = opt.ExtraOutputs
 
 	err = opt.validatePath()
 	if err != nil {
 		return err
 	}
 
+	err = p.setFormat(opt.Format)
+	if err != nil {
+		return err
+	}
+
 	err = p.setFileName(opt.FileName)
 	if err != nil {
 		return err
 	}
+	err = p.validateRelativePaths()
+	if err != nil {
+		return err
+	}
+	err = p.validateExtraOutputs()
+	if err != nil {
+		return err
+	}
 
 	err = p.FilterOptions.validateFilterOptions()
 	if err != nil {
 		return err
 	}
+	err = p.FilterOptions.validateDateSource()
+	if err != nil {
+		return err
+	}
+	err = p.FilterOptions.compilePatterns()
+	if err != nil {
+		return err
+	}
 
 	err = p.MarqueeOptions.validateColor()
 	if err != nil {
@@ -110,7 +181,36 @@ func (p *Params) parseOptFile(fsys fs.FS, fn string) error {
 	if err != nil {
 		return err
 	}
+	err = p.RandomizerOptions.validateOrder()
+	if err != nil {
+		return err
+	}
+	err = p.RandomizerOptions.validateTargetDuration()
+	if err != nil {
+		return err
+	}
+	err = p.RandomizerOptions.validateCount()
+	if err != nil {
+		return err
+	}
+	err = p.RandomizerOptions.parseBucketQuota()
+	if err != nil {
+		return err
+	}
+	err = p.ScanOptions.validateErrorPolicy()
+	if err != nil {
+		return err
+	}
+	err = p.ScanOptions.validateWorkers()
+	if err != nil {
+		return err
+	}
+	err = p.SummaryOptions.DurationBuckets.validate()
+	if err != nil {
+		return err
+	}
 	p.RandomizerOptions.setDefaultRatio()
+	p.RandomizerOptions.normalizeFolderRatios()
 	return nil
 }
 
@@ -118,16 +218,23 @@ func getParams() (*Params, error) {
 	p := &Params{}
 	flag.BoolVar(&p.extFlag, "ext", false, "If specified, collects unique file extensions")
 	flag.BoolVar(&p.playFlag, "play", false, "If specified, playlist will be played")
+	flag.BoolVar(&p.rebuildIndex, "rebuild-index", false, "If specified, media index is rebuilt by probing every file")
 	flag.IntVar(&p.minDuration, "mindur", 0, "Minimum duration of media files to collect (in seconds)")
 	flag.IntVar(&p.maxDuration, "maxdur", math.MaxInt32, "Maximum duration of media files to collect (in seconds)")
+	seed := flag.Int64("seed", 0, "Seed for selection and shuffling, reproduces a previous playlist (defaults to a random seed)")
 	fdate := flag.String("fdate", "20000101", "Files created after fdate will be considered")
 	tdate := flag.String("tdate", "20300101", "Files created before tdate will be considered")
 	optFile := flag.String("opt_file", "", "File to set options")
+	flag.StringVar(&p.reportFile, "report", "", "If specified, a run report is written to this file (.json or .html)")
 	flag.Parse()
 	err := p.setDateParams(*fdate, *tdate)
 	if err != nil {
 		return nil, err
 	}
+	err = p.validateReportFile()
+	if err != nil {
+		return nil, err
+	}
 	fsys := os.DirFS(".")
 	if *optFile != "" {
 		err = p.parseOptFile(fsys, *optFile)
@@ -135,5 +242,89 @@ func getParams() (*Params, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = p.validatePlay()
+	if err != nil {
+		return nil, err
+	}
+	p.RandomizerOptions.setSeed(*seed)
 	return p, nil
 }
+
+// validatePlay rejects -play when the output is a track list VLC can't open
+func (p *Params) validatePlay() error {
+	if p.playFlag && isTrackListFormat(p.Format) {
+		return fmt.Errorf("Cannot play %s, %s is a track list format; write a playlist and list %s in extra_outputs\n",
+			p.FileName, p.Format, p.Format)
+	}
+	return nil
+}
+
+func (p *Params) validateReportFile() error {
+	if p.reportFile == "" {
+		return nil
+	}
+	if ext := strings.ToLower(filepath.Ext(p.reportFile)); ext != reportExtension && ext != htmlExtension {
+		return fmt.Errorf("Report file should have %s or %s extension, got %s\n", reportExtension, htmlExtension, p.reportFile)
+	}
+	return nil
+}
+
+func (p *Params) isHTMLReport() bool {
+	return strings.ToLower(filepath.Ext(p.reportFile)) == htmlExtension
+}
+
+func (p *Params) getProblemsFileName() string {
+	return strings.TrimSuffix(p.FileName, filepath.Ext(p.FileName)) + "-problems.txt"
+}
+
+// validateRelativePaths rejects relative_paths for xspf, which always
+// writes absolute file locations
+func (p *Params) validateRelativePaths() error {
+	if p.RelativePaths && p.Format == FormatXspf {
+		return fmt.Errorf("relative_paths is not supported by %s, its locations are absolute file URLs\n", FormatXspf)
+	}
+	return nil
+}
+
+// validateExtraOutputs checks that every extra output has the extension of a
+// known format and differs from the playlist file
+func (p *Params) validateExtraOutputs() error {
+	for _, fn := range p.ExtraOutputs {
+		e, found := exporters.byExtension(filepath.Ext(fn))
+		if !found {
+			return fmt.Errorf("Extra output %s should have the extension of one of %v\n", fn, exporters.names())
+		}
+		if p.RelativePaths && e.Name() == FormatXspf {
+			return fmt.Errorf("Extra output %s: relative_paths is not supported by %s\n", fn, FormatXspf)
+		}
+		if filepath.Clean(fn) == filepath.Clean(p.FileName) {
+			return fmt.Errorf("Extra output %s would overwrite the playlist\n", fn)
+		}
+	}
+	return nil
+}
+
+// getPlayListBase gives the directory entries of the playlist file fn are made
+// relative to, empty when absolute paths are written
+func (p *Params) getPlayListBase(fn string) (string, error) {
+	if !p.RelativePaths {
+		return "", nil
+	}
+	fn, err := filepath.Abs(fn)
+	if err != nil {
+		return "", fmt.Errorf("Cannot resolve playlist directory: %w\n", err)
+	}
+	return filepath.Dir(fn), nil
+}
+
+// getIndexFileName resolves a relative media index path against the options file
+func (p *Params) getIndexFileName() string {
+	fn := p.ScanOptions.IndexFile
+	if fn == "" {
+		fn = defaultIndexFileName
+	}
+	if filepath.IsAbs(fn) {
+		return fn
+	}
+	return filepath.Join(filepath.Dir(p.optFile), fn)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"path/filepath"
 	"playmix/internal/assert"
 	"playmix/internal/mocks"
 	"testing"
@@ -22,8 +23,20 @@ func TestParamsSetFileNameIfNotProvided(t *testing.T) {
 
 func TestParamsSetFileNameError(t *testing.T) {
 	p := Params{}
-	err := p.setFileName("bad_name.xspf")
-	assert.ErrorRaised(t, "File param with extension specified raises error", err, true)
+	err := p.setFileName("bad_name.txt")
+	assert.ErrorRaised(t, "File param with unknown extension raises error", err, true)
+}
+
+func TestParamsSetFileNameByExtension(t *testing.T) {
+	p := Params{}
+	err := p.setFileName("mix.PLS")
+	assert.ErrorRaised(t, "Known extension should be accepted", err, false)
+	assert.Equal(t, "Should pick format from extension", p.Format, FormatPls)
+	assert.Equal(t, "Should keep file name", p.FileName, "mix.PLS")
+
+	p = Params{Format: FormatWpl}
+	err = p.setFileName("mix.asx")
+	assert.ErrorRaised(t, "Extension contradicting format raises error", err, true)
 }
 
 func TestParamsSetDateParams(t *testing.T) {
@@ -191,3 +204,112 @@ func TestParseOptInvalidFilterOptions(t *testing.T) {
 	err := p.parseOptFile(f, fn)
 	assert.ErrorRaised(t, "Should raise invalid filter options error", err, true)
 }
+
+func TestParseOptFileInvalidErrorPolicy(t *testing.T) {
+	p := Params{}
+	data := []byte(`{"media_path":"/media/", "scan_options": {"error_policy": "ignore"}}`)
+	fn := "options.json"
+	f := fstest.MapFS{
+		fn: {
+			Data:    data,
+			Mode:    0755,
+			ModTime: time.Now(),
+		},
+	}
+	err := p.parseOptFile(f, fn)
+	assert.ErrorRaised(t, "Should raise invalid error policy error", err, true)
+}
+
+func TestParamsGetProblemsFileName(t *testing.T) {
+	p := Params{}
+	p.setFileName("myplaylist")
+	assert.Equal(t, "Should derive problems file name", p.getProblemsFileName(), "myplaylist-problems.txt")
+}
+
+func TestParamsGetIndexFileName(t *testing.T) {
+	p := Params{optFile: "config/options.json"}
+	assert.Equal(t, "Should default next to options file", p.getIndexFileName(), "config/playmix-index.json")
+	p.ScanOptions.IndexFile = "cache/index.json"
+	assert.Equal(t, "Should resolve relative to options file", p.getIndexFileName(), "config/cache/index.json")
+	p.ScanOptions.IndexFile = "../cache/index.json"
+	assert.Equal(t, "Should resolve parent folder", p.getIndexFileName(), "cache/index.json")
+	abs := filepath.Join(string(filepath.Separator), "var", "cache", "index.json")
+	p.ScanOptions.IndexFile = abs
+	assert.Equal(t, "Should keep absolute path", p.getIndexFileName(), abs)
+}
+
+func TestParamsValidateReportFile(t *testing.T) {
+	p := Params{}
+	assert.ErrorRaised(t, "Should accept no report", p.validateReportFile(), false)
+	p.reportFile = "runs/report.json"
+	assert.ErrorRaised(t, "Should accept json report", p.validateReportFile(), false)
+	p.reportFile = "runs/report.HTML"
+	assert.ErrorRaised(t, "Should accept html report", p.validateReportFile(), false)
+	assert.Equal(t, "Should detect html report", p.isHTMLReport(), true)
+	p.reportFile = "report.txt"
+	assert.ErrorRaised(t, "Should reject unknown report format", p.validateReportFile(), true)
+	assert.Equal(t, "Should not detect html report", p.isHTMLReport(), false)
+}
+
+func TestParamsSetFormat(t *testing.T) {
+	p := Params{}
+	assert.ErrorRaised(t, "Should accept empty format", p.setFormat(""), false)
+	p.setFileName("myplaylist")
+	assert.Equal(t, "Should default to xspf", p.Format, FormatXspf)
+	assert.ErrorRaised(t, "Should accept m3u8", p.setFormat("m3u8"), false)
+	p.setFileName("myplaylist")
+	assert.Equal(t, "Should use format extension", p.FileName, "myplaylist.m3u8")
+	assert.ErrorRaised(t, "Should reject unknown format", p.setFormat("wav"), true)
+}
+
+func TestParseOptFileFormat(t *testing.T) {
+	p := Params{}
+	data := []byte(`{"media_path": "path/to/media/", "file_name": "mix", "format": "m3u8", "relative_paths": true}`)
+	fn := "options.json"
+	f := fstest.MapFS{fn: {Data: data, Mode: 0755, ModTime: time.Now()}}
+	err := p.parseOptFile(f, fn)
+	assert.ErrorRaised(t, "Parsing should succeed", err, false)
+	assert.Equal(t, "Should set format", p.Format, FormatM3u8)
+	assert.Equal(t, "Should set relative paths", p.RelativePaths, true)
+	assert.Equal(t, "Should set file name", p.FileName, "mix.m3u8")
+}
+
+func TestParamsGetPlayListBase(t *testing.T) {
+	p := Params{FileName: "mix.m3u8"}
+	base, err := p.getPlayListBase(p.FileName)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should be empty for absolute paths", base, "")
+	p.RelativePaths = true
+	base, err = p.getPlayListBase(p.FileName)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should resolve playlist directory", filepath.IsAbs(base), true)
+}
+
+func TestParamsValidateExtraOutputs(t *testing.T) {
+	p := Params{FileName: "mix.xspf", ExtraOutputs: []string{"mix.jsonl", "out/mix.CSV", "mix.m3u8"}}
+	assert.ErrorRaised(t, "Should accept known formats", p.validateExtraOutputs(), false)
+	p.ExtraOutputs = []string{"mix.txt"}
+	assert.ErrorRaised(t, "Should reject unknown format", p.validateExtraOutputs(), true)
+	p.ExtraOutputs = []string{"./mix.xspf"}
+	assert.ErrorRaised(t, "Should reject overwriting the playlist", p.validateExtraOutputs(), true)
+	p = Params{FileName: "mix.m3u8", RelativePaths: true, ExtraOutputs: []string{"copy.xspf"}}
+	assert.ErrorRaised(t, "Should reject relative paths for xspf output", p.validateExtraOutputs(), true)
+}
+
+func TestParamsValidatePlay(t *testing.T) {
+	p := Params{playFlag: true, Format: FormatM3u8}
+	assert.ErrorRaised(t, "Should play playlist formats", p.validatePlay(), false)
+	p.Format = FormatCsv
+	assert.ErrorRaised(t, "Should not play track lists", p.validatePlay(), true)
+	p.playFlag = false
+	assert.ErrorRaised(t, "Should write track lists without -play", p.validatePlay(), false)
+}
+
+func TestParseOptFileRelativePathsXspf(t *testing.T) {
+	p := Params{}
+	data := []byte(`{"media_path": "path/to/media/", "file_name": "mix.xspf", "relative_paths": true}`)
+	fn := "options.json"
+	f := fstest.MapFS{fn: {Data: data, Mode: 0755, ModTime: time.Now()}}
+	err := p.parseOptFile(f, fn)
+	assert.ErrorRaised(t, "Should reject relative paths for xspf", err, true)
+}
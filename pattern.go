@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// regexPrefix marks a pattern as an RE2 regular expression, other patterns are globs
+const regexPrefix = "re:"
+
+// pathPattern matches a file against a glob or a regular expression.
+// A file matches when either its relative path or its name matches.
+type pathPattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+func compilePattern(raw string) (pathPattern, error) {
+	expr, isRegex := strings.CutPrefix(raw, regexPrefix)
+	if !isRegex {
+		expr = globToRegex(raw)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return pathPattern{}, fmt.Errorf("Invalid pattern %s: %s\n", raw, err)
+	}
+	return pathPattern{raw: raw, re: re}, nil
+}
+
+func compilePatterns(raws []string) ([]pathPattern, error) {
+	var patterns []pathPattern
+	for _, raw := range raws {
+		p, err := compilePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func (p pathPattern) match(relPath, name string) bool {
+	return p.re.MatchString(relPath) || p.re.MatchString(name)
+}
+
+func matchAny(patterns []pathPattern, relPath, name string) bool {
+	for _, p := range patterns {
+		if p.match(relPath, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// globToRegex translates a glob into an anchored regular expression:
+// * and ? stay within a path segment, ** crosses segments and **/ also
+// matches no folder at all. Character classes are kept as they are.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(glob[i:]))
+				i = len(glob)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			// copy the whole rune, glob[i] is only its first byte
+			_, size := utf8.DecodeRuneInString(glob[i:])
+			b.WriteString(regexp.QuoteMeta(glob[i : i+size]))
+			i += size - 1
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"playmix/internal/assert"
+	"testing"
+)
+
+func TestGlobToRegex(t *testing.T) {
+	tests := []struct {
+		glob     string
+		path     string
+		expected bool
+	}{
+		{"*.mp4", "track.mp4", true},
+		{"*.mp4", "folder/track.mp4", false},
+		{"**/holiday/*.mp4", "holiday/beach.mp4", true},
+		{"**/holiday/*.mp4", "2023/summer/holiday/beach.mp4", true},
+		{"**/holiday/*.mp4", "2023/holiday/day1/beach.mp4", false},
+		{"music/**", "music/rock/track.mp3", true},
+		{"track_??.mp4", "track_01.mp4", true},
+		{"track_??.mp4", "track_001.mp4", false},
+		{"track_[0-4].mp4", "track_3.mp4", true},
+		{"track_[!0-4].mp4", "track_3.mp4", false},
+		{"a+b (1).mp4", "a+b (1).mp4", true},
+		{"**/Nyár/*.mp4", "2023/Nyár/strand.mp4", true},
+		{"**/Nyár/*.mp4", "2023/Nyar/strand.mp4", false},
+		{"*/Ősz_?.mp4", "Fotók/Ősz_é.mp4", true},
+		{"日本/*.mkv", "日本/東京.mkv", true},
+	}
+	for _, tt := range tests {
+		p, err := compilePattern(tt.glob)
+		assert.ErrorRaised(t, "Should compile "+tt.glob, err, false)
+		assert.Equal(t, "Glob "+tt.glob+" against "+tt.path, p.re.MatchString(tt.path), tt.expected)
+	}
+}
+
+func TestPathPatternMatchesNameOrPath(t *testing.T) {
+	p, _ := compilePattern("*.mkv")
+	assert.Equal(t, "Should match file name in subfolder", p.match("movies/film.mkv", "film.mkv"), true)
+
+	p, err := compilePattern(`re:^movies/.*\d{4}`)
+	assert.ErrorRaised(t, "Should compile regex", err, false)
+	assert.Equal(t, "Should match relative path", p.match("movies/film-1999.mkv", "film-1999.mkv"), true)
+	assert.Equal(t, "Should not match other folder", p.match("series/show-2001.mkv", "show-2001.mkv"), false)
+}
+
+func TestCompilePatternInvalidRegex(t *testing.T) {
+	_, err := compilePattern("re:(unclosed")
+	assert.ErrorRaised(t, "Should raise error for invalid regex", err, true)
+}
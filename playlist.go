@@ -2,44 +2,163 @@ package main
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"math"
 	"math/rand"
 	"path/filepath"
-
-	"github.com/alfg/mp4"
+	"slices"
+	"sort"
+	"time"
 )
 
 // TODO: let's sanitize track title by cutting the vlc record prefix
 type MediaItem struct {
 	AbsPath  string
+	RelPath  string
 	Location string
 	Dir      string
 	Name     string
 	Id       int
 	Duration float64
+	Width    int
+	Height   int
+	Codec    string
+	FPS      float64
+	HasAudio bool
+	Options  []string
+}
+
+func (m *MediaItem) setTrackInfo(info MediaInfo) {
+	m.Width = info.Width
+	m.Height = info.Height
+	m.Codec = info.Codec
+	m.FPS = info.FrameRate
+	m.HasAudio = info.HasAudio
 }
 
 // TODO: This dirName could be used writing a proper title
 func (m *MediaItem) getRelativeDir(rootParts []string) {
-	fileParts := getPathParts(m.AbsPath)
+	m.Dir = relativeDir(rootParts, m.AbsPath)
+}
+
+func relativeDir(rootParts []string, absPath string) string {
+	fileParts := getPathParts(absPath)
 
 	if len(fileParts) == len(rootParts) {
-		m.Dir = filepath.Base(filepath.Dir(m.AbsPath))
-	} else {
-		relativeParts := fileParts[len(rootParts)-1:]
-		m.Dir = filepath.Join(relativeParts...)
+		return filepath.Base(filepath.Dir(absPath))
 	}
+	relativeParts := fileParts[len(rootParts)-1:]
+	return filepath.Join(relativeParts...)
+}
+
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
 }
 
 type Summarizer struct {
-	dBucket       DurationBucket
-	ratio         uint8
-	totalDuration float64
-	totalScanned  int
-	totalSelected int
+	dBucket        DurationBucket
+	ratio          uint8
+	seed           int64
+	targetDuration int
+	tolerance      int
+	count          int
+	quotas         []bandResult
+	totalDuration  float64
+	totalScanned   int
+	totalSelected  int
+	skipped        []SkippedFile
+	cached         int
+	rejected       map[string]int
+	folders        map[string]*folderRatio
+	dirs           map[string]*dirCount
+	timings        []ReportTiming
+}
+
+// addTiming records how long a step of the run took
+func (s *Summarizer) addTiming(name string, elapsed time.Duration) {
+	s.timings = append(s.timings, ReportTiming{Name: name, Seconds: elapsed.Seconds()})
+}
+
+// folderRatio tracks the selection under a folder_ratios rule
+type folderRatio struct {
+	requested uint8
+	scanned   int
+	selected  int
+}
+
+func (f folderRatio) getRealRatio() float64 {
+	return float64(f.selected) / float64(f.scanned) * 100
+}
+
+func (s *Summarizer) scanFolder(folder string, ratio uint8) {
+	if s.folders == nil {
+		s.folders = map[string]*folderRatio{}
+	}
+	if _, found := s.folders[folder]; !found {
+		s.folders[folder] = &folderRatio{requested: ratio}
+	}
+	s.folders[folder].scanned++
+}
+
+func (s Summarizer) sortedFolders() []string {
+	return slices.Sorted(maps.Keys(s.folders))
+}
+
+func (s *Summarizer) selectFolder(folder string) {
+	if f, found := s.folders[folder]; found {
+		f.selected++
+	}
+}
+
+// scan counts a file passing the filters under its folder_ratios rule and
+// folder, and returns the ratio it is selected with
+func (s *Summarizer) scan(options RandomizerOptions, relPath, dir string) uint8 {
+	ratio, folder := options.ratioFor(relPath)
+	if folder != "" {
+		s.scanFolder(folder, ratio)
+	}
+	s.scanDir(dir)
+	s.totalScanned++
+	return ratio
+}
+
+// dirCount tracks the selection of every folder holding media, by MediaItem.Dir
+type dirCount struct {
+	scanned  int
+	selected int
+}
+
+func (s *Summarizer) scanDir(dir string) {
+	if s.dirs == nil {
+		s.dirs = map[string]*dirCount{}
+	}
+	if _, found := s.dirs[dir]; !found {
+		s.dirs[dir] = &dirCount{}
+	}
+	s.dirs[dir].scanned++
+}
+
+func (s *Summarizer) selectDir(dir string) {
+	if d, found := s.dirs[dir]; found {
+		d.selected++
+	}
+}
+
+// reject counts a file left out by the named filter
+func (s *Summarizer) reject(filter string) {
+	if s.rejected == nil {
+		s.rejected = map[string]int{}
+	}
+	s.rejected[filter]++
+}
+
+func (s *Summarizer) skip(path string, err error) {
+	s.skipped = append(s.skipped, SkippedFile{Path: path, Reason: err.Error()})
 }
 
 func (s Summarizer) getRealRatio() float64 {
@@ -52,49 +171,116 @@ func (s Summarizer) getData(w io.Writer) {
 	s.dBucket.summarize(w)
 	fmt.Fprintf(w, "Total duration is: %f sec -- (%f) minutes\n", s.totalDuration, s.totalDuration/60)
 	fmt.Fprintf(w, "Total selected: %d -- required ratio: %d -- got: %.2f%%\n", s.totalSelected, s.ratio, s.getRealRatio())
+	if s.targetDuration > 0 {
+		fmt.Fprintf(w, "Target duration: %d sec (+/- %d) -- got: %.0f sec\n", s.targetDuration, s.tolerance, s.totalDuration)
+	}
+	if s.count > 0 {
+		fmt.Fprintf(w, "Target count: %d -- got: %d\n", s.count, s.totalSelected)
+	}
+	for _, q := range s.quotas {
+		fmt.Fprintf(w, "Bucket quota %s: %d -- required share: %.0f%% -- got: %.2f%%\n", q.label, q.selected, q.share, percent(q.selected, s.totalSelected))
+	}
+	for _, folder := range s.sortedFolders() {
+		f := s.folders[folder]
+		fmt.Fprintf(w, "Folder %s selected: %d -- required ratio: %d -- got: %.2f%%\n", folder, f.selected, f.requested, f.getRealRatio())
+	}
+	if len(s.skipped) > 0 {
+		fmt.Fprintf(w, "Skipped unreadable files: %d\n", len(s.skipped))
+	}
+	if s.cached > 0 {
+		fmt.Fprintf(w, "Durations read from index: %d\n", s.cached)
+	}
+	filters := slices.Sorted(maps.Keys(s.rejected))
+	for _, filter := range filters {
+		fmt.Fprintf(w, "Rejected by %s filter: %d\n", filter, s.rejected[filter])
+	}
+	fmt.Fprintf(w, "Seed: %d\n", s.seed)
+}
+
+func (s Summarizer) writeProblems(w io.Writer) error {
+	for _, f := range s.skipped {
+		_, err := fmt.Fprintf(w, "%s: %s\n", f.Path, f.Reason)
+		if err != nil {
+			return fmt.Errorf("Error writing problems report: %w\n", err)
+		}
+	}
+	return nil
 }
 
+// DurationBucket counts the files and sums the durations falling between
+// consecutive edges. The first bucket holds durations below the first edge,
+// the others run up to and including their upper edge, and the last one
+// holds everything above the last edge. The zero value uses defaultBucketEdges.
 type DurationBucket struct {
-	Dur0_5     int
-	Dur5_10    int
-	Dur10_30   int
-	Dur30_60   int
-	Dur60_180  int
-	Dur180_240 int
-	DurOver240 int
+	edges     []float64
+	counts    []int
+	durations []float64
+}
+
+func newDurationBucket(edges []float64) DurationBucket {
+	if len(edges) == 0 {
+		edges = defaultBucketEdges
+	}
+	return DurationBucket{
+		edges:     edges,
+		counts:    make([]int, len(edges)+1),
+		durations: make([]float64, len(edges)+1),
+	}
+}
+
+func (d *DurationBucket) init() {
+	if d.counts == nil {
+		*d = newDurationBucket(d.edges)
+	}
+}
+
+func (d *DurationBucket) index(duration float64) int {
+	if duration < d.edges[0] {
+		return 0
+	}
+	return sort.Search(len(d.edges)-1, func(i int) bool {
+		return duration <= d.edges[i+1]
+	}) + 1
 }
 
 func (d *DurationBucket) allocate(duration float64) {
-	switch {
-	case duration < 5:
-		d.Dur0_5++
-	case duration <= 10:
-		d.Dur5_10++
-	case duration <= 30:
-		d.Dur10_30++
-	case duration <= 60:
-		d.Dur30_60++
-	case duration <= 180:
-		d.Dur60_180++
-	case duration <= 240:
-		d.Dur180_240++
-	default:
-		d.DurOver240++
+	d.init()
+	i := d.index(duration)
+	d.counts[i]++
+	d.durations[i] += duration
+}
+
+func (d *DurationBucket) label(i int) string {
+	switch i {
+	case 0:
+		return fmt.Sprintf("<%g", d.edges[0])
+	case len(d.edges):
+		return fmt.Sprintf("%g<", d.edges[i-1])
 	}
+	return fmt.Sprintf("%g-%g", d.edges[i-1], d.edges[i])
+}
+
+func (d *DurationBucket) total() (total int) {
+	for _, n := range d.counts {
+		total += n
+	}
+	return
 }
 
 func (d *DurationBucket) summarize(w io.Writer) {
-	fmt.Fprintf(w, "Bucket <5 seconds: %d\n", d.Dur0_5)
-	fmt.Fprintf(w, "Bucket 5-10 seconds: %d\n", d.Dur5_10)
-	fmt.Fprintf(w, "Bucket 10-30 seconds: %d\n", d.Dur10_30)
-	fmt.Fprintf(w, "Bucket 30-60 seconds: %d\n", d.Dur30_60)
-	fmt.Fprintf(w, "Bucket 60-180 seconds: %d\n", d.Dur60_180)
-	fmt.Fprintf(w, "Bucket 180-240 seconds: %d\n", d.Dur180_240)
-	fmt.Fprintf(w, "Bucket 240< seconds: %d\n", d.DurOver240)
+	d.init()
+	total := d.total()
+	for i, n := range d.counts {
+		percent := 0.0
+		if total > 0 {
+			percent = float64(n) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "Bucket %s seconds: %d -- %.2f%% -- %.0f sec\n", d.label(i), n, percent, d.durations[i])
+	}
 }
 
-func selector(ratio int) bool {
-	n := rand.Intn(100)
+func selector(rng *rand.Rand, ratio int) bool {
+	n := rng.Intn(100)
 	if n < ratio {
 		return true
 	}
@@ -125,59 +311,219 @@ func isIncluded(rootParts []string, path string, include []string) bool {
 	return false
 }
 
+// rejectedBy returns the first walk filter leaving the file out, or "" when it passes all of them
+func rejectedBy(rootParts []string, path, absPath string, d fs.DirEntry, params Params) string {
+	switch {
+	case !isIncluded(rootParts, absPath, params.FilterOptions.IncludeF):
+		return filterIncludeFolder
+	case !params.FilterOptions.isPatternIncluded(path, d.Name()):
+		return filterIncludePattern
+	case params.FilterOptions.isPatternExcluded(path, d.Name()):
+		return filterExcludePattern
+	case !dateFilter(d, params):
+		return filterDate
+	}
+	return ""
+}
+
+// audioFilter drops files known to have no audio track when audio is played
+func audioFilter(info MediaInfo, options PlayOptions) bool {
+	if !options.Audio || !info.hasTrackInfo() {
+		return true
+	}
+	return info.HasAudio
+}
+
+func inDateRange(date time.Time, params Params) bool {
+	return date.After(params.fdate) && date.Before(params.tdate)
+}
+
+// dateFilter checks the dates known while walking: the modification time
+// or the date in the file name. Embedded dates are checked by probedDateFilter.
 func dateFilter(d fs.DirEntry, params Params) bool {
+	switch {
+	case params.FilterOptions.needsProbe():
+		return true
+	case params.FilterOptions.DateSource == DateSourceFileName:
+		date, found := fileNameDate(d.Name())
+		return found && inDateRange(date, params)
+	}
 	file, _ := d.Info()
-	mTime := file.ModTime().UTC()
-	if mTime.After(params.fdate) && mTime.Before(params.tdate) {
+	return inDateRange(file.ModTime().UTC(), params)
+}
+
+// probedDateFilter checks the date sources needing the probed metadata.
+// first-available falls back to the file name, then to the modification time.
+func probedDateFilter(c candidate, info MediaInfo, params Params) bool {
+	if !params.FilterOptions.needsProbe() {
 		return true
 	}
-	return false
+	date := info.CreationTime
+	if date.IsZero() && params.FilterOptions.DateSource == DateSourceFirstAvailable {
+		var found bool
+		if date, found = fileNameDate(c.name); !found {
+			date = c.modTime.UTC()
+		}
+	}
+	return !date.IsZero() && inDateRange(date, params)
 }
 
-func collectMediaContent(p string, fsys fs.FS, params Params) ([]MediaItem, Summarizer, error) {
+// collectMediaContent walks fsys for candidates passing the filters and the selector,
+// then probes their durations concurrently. Items keep the walk order. With a
+// date source read from the container, every file is probed and is only counted
+// as scanned and drawn by the selector once its date is in range.
+func collectMediaContent(rng *rand.Rand, p string, fsys fs.FS, params Params) ([]MediaItem, Summarizer, error) {
 	var items []MediaItem
+	var candidates []candidate
 	rootParts := getPathParts(p)
 	summary := Summarizer{ // TODO: factors this out to be a parameter
 		ratio:         params.RandomizerOptions.Ratio,
+		seed:          params.RandomizerOptions.Seed,
 		totalScanned:  0,
 		totalSelected: 0,
 		totalDuration: 0,
-		dBucket:       DurationBucket{},
+		dBucket:       newDurationBucket(params.SummaryOptions.DurationBuckets),
 	}
 	idx := 0
+	probeDate := params.FilterOptions.needsProbe()
 	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return err
+			if !params.ScanOptions.skipErrors() {
+				return err
+			}
+			summary.skip(path, err)
+			return nil
 		}
 		if d.IsDir() && toSkip(d.Name(), params.FilterOptions.Skipf) {
 			return filepath.SkipDir
 		}
 		absPath := filepath.Join(p, path)
-		if !d.IsDir() && isMediaFile(filepath.Ext(d.Name())) && isIncluded(rootParts, absPath, params.FilterOptions.IncludeF) && dateFilter(d, params) {
-			if selector(int(params.RandomizerOptions.Ratio)) {
-				duration, err := getDuration(fsys, path)
+		if !d.IsDir() && isMediaFile(filepath.Ext(d.Name())) {
+			if filter := rejectedBy(rootParts, path, absPath, d, params); filter != "" {
+				summary.reject(filter)
+				return nil
+			}
+			// an embedded date is only known after probing, so the file is
+			// counted and the selector drawn once the date filter passed
+			if probeDate {
+				info, err := d.Info()
+				if err != nil && !params.ScanOptions.skipErrors() {
+					return err
+				}
 				if err != nil {
+					summary.skip(path, err)
+				} else {
+					candidates = append(candidates, candidate{id: idx, path: path, absPath: absPath, name: d.Name(), size: info.Size(), modTime: info.ModTime()})
+				}
+				idx++
+				return nil
+			}
+			ratio := summary.scan(params.RandomizerOptions, path, relativeDir(rootParts, absPath))
+			if selector(rng, int(ratio)) {
+				info, err := d.Info()
+				if err != nil && !params.ScanOptions.skipErrors() {
 					return err
 				}
-				summary.dBucket.allocate(duration)
-				if duration > float64(params.minDuration) && duration < float64(params.maxDuration) {
-					location := getUrlEncodedPath(absPath)
-					item := MediaItem{Id: idx, AbsPath: absPath, Location: location, Name: d.Name(), Duration: duration}
-					item.getRelativeDir(rootParts)
-					items = append(items, item)
-					summary.totalDuration += duration
-					summary.totalSelected++
+				if err != nil {
+					summary.skip(path, err)
+				} else {
+					candidates = append(candidates, candidate{id: idx, path: path, absPath: absPath, name: d.Name(), size: info.Size(), modTime: info.ModTime()})
 				}
 			}
-			summary.totalScanned++
 			idx++
-			if summary.totalScanned%500 == 0 {
-				fmt.Printf("Processed %d files\n", summary.totalScanned)
-			}
 		}
 		return nil
 	})
-	return items, summary, err
+	if err != nil {
+		return items, summary, err
+	}
+
+	results := make([]probeResult, len(candidates))
+	var pending []int
+	for i, c := range candidates {
+		if entry, found := params.index.lookup(c); found {
+			results[i] = probeResult{info: entry.MediaInfo}
+			summary.cached++
+		} else {
+			pending = append(pending, i)
+		}
+	}
+	probeCandidates(fsys, candidates, pending, results, params.ScanOptions.workerCount(), !params.ScanOptions.skipErrors())
+	if !params.ScanOptions.skipErrors() {
+		// fail before storing anything in the index, skipped results carry no info
+		for _, r := range results {
+			if r.err != nil && !errors.Is(r.err, errProbeSkipped) {
+				return nil, summary, r.err
+			}
+		}
+	}
+	// with a count and no quotas, items stream into the reservoir instead of
+	// being collected in full
+	var sample *reservoir
+	if params.RandomizerOptions.Count > 0 && len(params.RandomizerOptions.bands) == 0 {
+		sample = newReservoir(rng, params.RandomizerOptions.Count)
+	}
+	for i, c := range candidates {
+		info, err := results[i].info, results[i].err
+		if err != nil && !params.ScanOptions.skipErrors() {
+			return nil, summary, err
+		}
+		if err != nil {
+			summary.skip(c.path, err)
+			continue
+		}
+		params.index.store(c, info)
+		if !probedDateFilter(c, info, params) {
+			summary.reject(filterDate)
+			continue
+		}
+		if probeDate {
+			ratio := summary.scan(params.RandomizerOptions, c.path, relativeDir(rootParts, c.absPath))
+			if !selector(rng, int(ratio)) {
+				continue
+			}
+		}
+		duration := info.Duration
+		summary.dBucket.allocate(duration)
+		if duration <= float64(params.minDuration) || duration >= float64(params.maxDuration) {
+			continue
+		}
+		if !audioFilter(info, params.PlayOptions) {
+			summary.reject(filterNoAudio)
+			continue
+		}
+		location := getUrlEncodedPath(c.absPath)
+		item := MediaItem{Id: c.id, AbsPath: c.absPath, RelPath: c.path, Location: location, Name: c.name, Duration: duration}
+		item.setTrackInfo(info)
+		item.getRelativeDir(rootParts)
+		if sample != nil {
+			sample.add(item)
+		} else {
+			items = append(items, item)
+		}
+	}
+	if sample != nil {
+		items = sample.sample()
+		summary.count = params.RandomizerOptions.Count
+	}
+	if bands := params.RandomizerOptions.bands; len(bands) > 0 {
+		items, summary.quotas = selectByQuota(rng, items, bands, params.RandomizerOptions.Count)
+		summary.count = params.RandomizerOptions.Count
+	}
+	if target := params.RandomizerOptions.TargetDuration; target > 0 {
+		items = selectByDuration(rng, items, target, params.RandomizerOptions.Tolerance)
+		summary.targetDuration = target
+		summary.tolerance = params.RandomizerOptions.Tolerance
+	}
+	for _, item := range items {
+		summary.totalDuration += item.Duration
+		summary.totalSelected++
+		if _, folder := params.RandomizerOptions.ratioFor(item.RelPath); folder != "" {
+			summary.selectFolder(folder)
+		}
+		summary.selectDir(item.Dir)
+	}
+	return items, summary, nil
 }
 
 type unbufferedReaderAt struct {
@@ -197,37 +543,44 @@ func (u *unbufferedReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
 }
 
 func getDuration(fsys fs.FS, p string) (duration float64, err error) {
+	info, err := getMediaInfo(fsys, p)
+	return info.Duration, err
+}
+
+func getMediaInfo(fsys fs.FS, p string) (MediaInfo, error) {
 	file, err := fsys.Open(p)
 	if err != nil {
-		return 0, err
+		return MediaInfo{}, err
 	}
 	defer file.Close()
-	info, err := file.Stat()
+	stat, err := file.Stat()
 	if err != nil {
-		return 0, err
+		return MediaInfo{}, err
 	}
-	readerAt := NewUnbufferedReaderAt(file, info.Size())
-	mp4, err := mp4.OpenFromReader(readerAt, info.Size())
-	if err != nil {
-		return 0, err
+	readerAt := NewUnbufferedReaderAt(file, stat.Size())
+	header := make([]byte, sniffSize)
+	n, err := readerAt.ReadAt(header, 0)
+	if n == 0 {
+		return MediaInfo{}, fmt.Errorf("File cannot be read: %s: %v", p, err)
 	}
-	if mp4.Moov == nil {
-		return 0, fmt.Errorf("Moov box not found for %s. Is this mp4?", p)
+	prober, found := probers.sniff(header[:n])
+	if !found {
+		return MediaInfo{}, fmt.Errorf("Unrecognized container for %s", p)
 	}
-	rawDuration := float64(mp4.Moov.Mvhd.Duration)
-	timeScale := float64(mp4.Moov.Mvhd.Timescale)
-
-	duration = rawDuration / timeScale
-	return duration, nil
+	info, err := probe(prober, readerAt, stat.Size())
+	if err != nil {
+		return MediaInfo{}, fmt.Errorf("%s (%s) for %s", err, prober.Name(), p)
+	}
+	return info, nil
 }
 
-func randomizePlaylist(playlist []MediaItem, stabilizer int) {
+func randomizePlaylist(rng *rand.Rand, playlist []MediaItem, stabilizer int) {
 	if stabilizer < 2 {
-		rand.Shuffle(len(playlist), func(i, j int) {
+		rng.Shuffle(len(playlist), func(i, j int) {
 			playlist[i], playlist[j] = playlist[j], playlist[i]
 		})
 	} else {
-		rand.Shuffle(len(playlist), func(i, j int) {
+		rng.Shuffle(len(playlist), func(i, j int) {
 			if i%stabilizer != 0 {
 				playlist[i], playlist[j] = playlist[j], playlist[i]
 			}
@@ -256,16 +609,7 @@ func buildPlayList(content []MediaItem, options PlayOptions) *PlayList {
 	tracks := []*Track{}
 
 	for i, media := range content {
-		ext := &Extension{Application: ExtensionApplication, Id: i}
-		if !options.Audio {
-			ext.Options = append(ext.Options, options.StringifyAudio())
-		}
-		if options.StartTime > 0 {
-			ext.Options = append(ext.Options, options.StringifyStartTime())
-		}
-		if options.StopTime > 0 {
-			ext.Options = append(ext.Options, options.StringifyStopTime())
-		}
+		ext := &Extension{Application: ExtensionApplication, Id: i, Options: itemOptions(media, options)}
 		track := &Track{Location: media.Location, Title: media.Name, Duration: math.Round(media.Duration), Ext: *ext}
 		tracks = append(tracks, track)
 	}
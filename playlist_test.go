@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"math"
+	"math/rand"
+	"path/filepath"
 	"playmix/internal/assert"
 	"playmix/internal/mocks"
 	"strconv"
@@ -22,14 +24,11 @@ func TestGetRealRation(t *testing.T) {
 }
 
 func TestGetData(t *testing.T) {
-	d := DurationBucket{
-		Dur0_5:     1,
-		Dur5_10:    2,
-		Dur10_30:   3,
-		Dur30_60:   4,
-		Dur60_180:  5,
-		Dur180_240: 6,
-		DurOver240: 7,
+	d := newDurationBucket(nil)
+	for i, duration := range []float64{1, 6, 15, 40, 100, 200, 300} {
+		for n := 0; n <= i; n++ {
+			d.allocate(duration)
+		}
 	}
 	s := Summarizer{totalScanned: 100, totalSelected: 100, dBucket: d, totalDuration: 300, ratio: 100}
 	var buf bytes.Buffer
@@ -39,13 +38,13 @@ func TestGetData(t *testing.T) {
 
 	assert.Equal(t, "Should be equal", splits[0], "Total scanned: 100")
 	assert.Equal(t, "Should be equal", splits[1], "Duration distribution:")
-	assert.Equal(t, "Should be equal", splits[2], "Bucket <5 seconds: 1")
-	assert.Equal(t, "Should be equal", splits[3], "Bucket 5-10 seconds: 2")
-	assert.Equal(t, "Should be equal", splits[4], "Bucket 10-30 seconds: 3")
-	assert.Equal(t, "Should be equal", splits[5], "Bucket 30-60 seconds: 4")
-	assert.Equal(t, "Should be equal", splits[6], "Bucket 60-180 seconds: 5")
-	assert.Equal(t, "Should be equal", splits[7], "Bucket 180-240 seconds: 6")
-	assert.Equal(t, "Should be equal", splits[8], "Bucket 240< seconds: 7")
+	assert.Equal(t, "Should be equal", splits[2], "Bucket <5 seconds: 1 -- 3.57% -- 1 sec")
+	assert.Equal(t, "Should be equal", splits[3], "Bucket 5-10 seconds: 2 -- 7.14% -- 12 sec")
+	assert.Equal(t, "Should be equal", splits[4], "Bucket 10-30 seconds: 3 -- 10.71% -- 45 sec")
+	assert.Equal(t, "Should be equal", splits[5], "Bucket 30-60 seconds: 4 -- 14.29% -- 160 sec")
+	assert.Equal(t, "Should be equal", splits[6], "Bucket 60-180 seconds: 5 -- 17.86% -- 500 sec")
+	assert.Equal(t, "Should be equal", splits[7], "Bucket 180-240 seconds: 6 -- 21.43% -- 1200 sec")
+	assert.Equal(t, "Should be equal", splits[8], "Bucket 240< seconds: 7 -- 25.00% -- 2100 sec")
 	assert.Equal(t, "Should be equal", splits[9], "Total duration is: 300.000000 sec -- (5.000000) minutes")
 	assert.Equal(t, "Should be equal", splits[10], "Total selected: 100 -- required ratio: 100 -- got: 100.00%")
 }
@@ -89,22 +88,19 @@ func TestPlaylist_allocate(t *testing.T) {
 	for _, duration := range durations {
 		durB.allocate(duration)
 	}
-	tests := []struct {
-		name     string
-		got      int
-		expected int
-	}{
-		{"Dur0_5", durB.Dur0_5, 1},
-		{"Dur5_10", durB.Dur5_10, 2},
-		{"Dur10_30", durB.Dur10_30, 2},
-		{"Dur30_60", durB.Dur30_60, 1},
-		{"Dur60_180", durB.Dur60_180, 2},
-		{"Dur180_240", durB.Dur180_240, 1},
-		{"DurOver240", durB.DurOver240, 1},
-	}
-	for _, tt := range tests {
-		assert.Equal(t, tt.name, tt.got, tt.expected)
+	assert.EqualSlice(t, "Should allocate to default buckets", durB.counts, []int{1, 2, 2, 1, 2, 1, 1})
+	assert.Equal(t, "Should sum durations per bucket", durB.durations[1], 16.38)
+}
+
+func TestDurationBucketCustomEdges(t *testing.T) {
+	durB := newDurationBucket([]float64{60, 600})
+	for _, duration := range []float64{30, 60, 61, 600, 601} {
+		durB.allocate(duration)
 	}
+	assert.EqualSlice(t, "Should allocate to custom buckets", durB.counts, []int{1, 3, 1})
+	assert.Equal(t, "Should label first bucket", durB.label(0), "<60")
+	assert.Equal(t, "Should label middle bucket", durB.label(1), "60-600")
+	assert.Equal(t, "Should label last bucket", durB.label(2), "600<")
 }
 
 func TestPlaylistToSkip(t *testing.T) {
@@ -223,7 +219,7 @@ func TestCollectMediaContentRaisesWalkError(t *testing.T) {
 	f := mocks.FakeSys{}
 	randomizeOpts := RandomizerOptions{Ratio: 100}
 	params := Params{fdate: fdate, tdate: tdate, RandomizerOptions: randomizeOpts}
-	_, _, err := collectMediaContent("/home/Music", f, params)
+	_, _, err := collectMediaContent(_newRand(), "/home/Music", f, params)
 	assert.ErrorRaised(t, "Should raise error", err, true)
 }
 
@@ -239,7 +235,7 @@ func TestCollectMediaContentRaisesErrorNonMediaFile(t *testing.T) {
 	}
 	randomizeOpts := RandomizerOptions{Ratio: 100}
 	params := Params{fdate: fdate, tdate: tdate, RandomizerOptions: randomizeOpts}
-	_, _, err := collectMediaContent("/home/Music", fsys, params)
+	_, _, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
 	assert.ErrorRaised(t, "Should raise error", err, true)
 }
 
@@ -266,7 +262,7 @@ func TestCollectMediaContentDuration(t *testing.T) {
 			ModTime: modTime,
 		},
 	}
-	items, summary, _ := collectMediaContent("/home/Music", fsys, params)
+	items, summary, _ := collectMediaContent(_newRand(), "/home/Music", fsys, params)
 	assert.Equal(t, "Should select one file", items[0].Name, "should_be_selected.mp4")
 	assert.Equal(t, "Should select one file", summary.totalSelected, 1)
 }
@@ -293,7 +289,7 @@ func TestCollectMediaContentDateFilter(t *testing.T) {
 			ModTime: time.Date(2024, 3, 27, 0, 0, 0, 0, time.UTC),
 		},
 	}
-	items, summary, _ := collectMediaContent("/home/Music", fsys, params)
+	items, summary, _ := collectMediaContent(_newRand(), "/home/Music", fsys, params)
 	assert.Equal(t, "Should select one file", items[0].Name, "should_be_selected.mp4")
 	assert.Equal(t, "Should select one file", summary.totalSelected, 1)
 }
@@ -329,7 +325,7 @@ func TestCollectMediaContentSkipFilter(t *testing.T) {
 			ModTime: modTime,
 		},
 	}
-	items, summary, _ := collectMediaContent("/home/Music", fsys, params)
+	items, summary, _ := collectMediaContent(_newRand(), "/home/Music", fsys, params)
 	assert.Equal(t, "Id should be 0 for first item", items[0].Id, 0)
 	assert.Equal(t, "Id should be 1 for second item", items[1].Id, 1)
 	assert.Equal(t, "Should select two files", summary.totalSelected, 2)
@@ -364,7 +360,7 @@ func TestCollectMediaContentIncludeFilter(t *testing.T) {
 			ModTime: modTime,
 		},
 	}
-	items, summary, _ := collectMediaContent("/home/Music", fsys, params)
+	items, summary, _ := collectMediaContent(_newRand(), "/home/Music", fsys, params)
 	assert.Equal(t, "Should select one file", items[0].Name, "should_be_selected.mp4")
 	assert.Equal(t, "Should select one file", summary.totalSelected, 1)
 }
@@ -392,11 +388,16 @@ func TestCollectMediaContentSelector(t *testing.T) {
 			ModTime: time.Now(),
 		},
 	}
-	items, summary, _ := collectMediaContent("/home/Music", fsys, params)
+	items, summary, _ := collectMediaContent(_newRand(), "/home/Music", fsys, params)
 	assert.Equal(t, "Should not select anything", len(items), 0)
 	assert.Equal(t, "Should not select anything", summary.totalSelected, 0)
 }
 
+// _newRand returns a generator with a fixed seed so randomized tests are repeatable
+func _newRand() *rand.Rand {
+	return rand.New(rand.NewSource(42))
+}
+
 func _createMediaItems(length int) (items []MediaItem, indices []int) {
 	for i := 0; i < length; {
 		trackName := "track_" + strconv.Itoa(i) + ".mp4"
@@ -425,7 +426,7 @@ func _getIndices(items []MediaItem) (newIndices []int) {
 
 func TestRandomizePlaylistWithoutStabilizer(t *testing.T) {
 	mItems, indices := _createMediaItems(30)
-	randomizePlaylist(mItems, 1)
+	randomizePlaylist(_newRand(), mItems, 1)
 
 	newIndices := _getIndices(mItems)
 	assert.NotEqualSlice(t, "Indices should be different", newIndices, indices)
@@ -433,7 +434,7 @@ func TestRandomizePlaylistWithoutStabilizer(t *testing.T) {
 
 func TestRandomizePlaylistWithStabilizer(t *testing.T) {
 	mItems, indices := _createMediaItems(30)
-	randomizePlaylist(mItems, 2)
+	randomizePlaylist(_newRand(), mItems, 2)
 
 	newIndices := _getIndices(mItems)
 	assert.NotEqualSlice(t, "Indices should be different", newIndices, indices)
@@ -441,11 +442,11 @@ func TestRandomizePlaylistWithStabilizer(t *testing.T) {
 
 func TestRandomizePlaylistStabilizerLessRandom(t *testing.T) {
 	nonStabilized, idxNonStabilized := _createMediaItems(100)
-	randomizePlaylist(nonStabilized, 0)
+	randomizePlaylist(_newRand(), nonStabilized, 0)
 	newIndiecesNonStabilized := _getIndices(nonStabilized)
 
 	stabilized, idxStabilized := _createMediaItems(100)
-	randomizePlaylist(stabilized, 2)
+	randomizePlaylist(_newRand(), stabilized, 2)
 	newIndicesStablized := _getIndices(stabilized)
 
 	assert.NotEqualSlice(t, "Indices should be different", newIndiecesNonStabilized, idxNonStabilized)
@@ -602,3 +603,411 @@ func TestBuildPlaylist(t *testing.T) {
 	assert.Equal(t, "Should have correct title", pl.Tl.Tracks[0].Title, "track.mp4")
 	assert.Equal(t, "Should have correct duration", pl.Tl.Tracks[0].Duration, 180)
 }
+
+func TestCollectMediaContentSkipsUnreadableFiles(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+		ScanOptions:       ScanOptions{ErrorPolicy: ErrorPolicySkip},
+	}
+	fsys := fstest.MapFS{
+		"corrupt.mp4": {
+			Data:    []byte("truncated"),
+			Mode:    0755,
+			ModTime: modTime,
+		},
+		"good.mp4": {
+			Data:    mocks.CreateData(60),
+			Mode:    0755,
+			ModTime: modTime,
+		},
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should select the readable file", len(items), 1)
+	assert.Equal(t, "Should select the readable file", items[0].Name, "good.mp4")
+	assert.Equal(t, "Should record skipped file", len(summary.skipped), 1)
+	assert.Equal(t, "Should record skipped path", summary.skipped[0].Path, "corrupt.mp4")
+	assert.Equal(t, "Should count both files as scanned", summary.totalScanned, 2)
+}
+
+func TestCollectMediaContentSkipsWalkError(t *testing.T) {
+	params := Params{
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+		ScanOptions:       ScanOptions{ErrorPolicy: ErrorPolicySkipAndReport},
+	}
+	_, summary, err := collectMediaContent(_newRand(), "/home/Music", mocks.FakeSys{}, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should record walk error", len(summary.skipped), 1)
+}
+
+func TestGetDataSkipped(t *testing.T) {
+	s := Summarizer{totalScanned: 2, totalSelected: 1, ratio: 100, skipped: []SkippedFile{{Path: "a.mp4", Reason: "bad"}}}
+	var buf bytes.Buffer
+	s.getData(&buf)
+	splits := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "Should report skipped files", splits[11], "Skipped unreadable files: 1")
+}
+
+func TestWriteProblems(t *testing.T) {
+	s := Summarizer{skipped: []SkippedFile{
+		{Path: "a/corrupt.mp4", Reason: "Moov box not found"},
+		{Path: "b/short.avi", Reason: "unexpected EOF"},
+	}}
+	var buf bytes.Buffer
+	err := s.writeProblems(&buf)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should list one problem per line", buf.String(), "a/corrupt.mp4: Moov box not found\nb/short.avi: unexpected EOF\n")
+
+	err = s.writeProblems(mocks.FakeWriter{})
+	assert.ErrorRaised(t, "Should raise write error", err, true)
+}
+
+func TestCollectMediaContentDeterministicWithWorkers(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i := 0; i < 40; i++ {
+		fn := "folder_" + strconv.Itoa(i%4) + "/track_" + strconv.Itoa(i) + ".mp4"
+		fsys[fn] = &fstest.MapFile{Data: mocks.CreateData(10 + i), Mode: 0755, ModTime: modTime}
+	}
+	var previous []int
+	for _, workers := range []int{1, 3, 16} {
+		params := Params{
+			fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+			tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+			maxDuration:       math.MaxInt32,
+			RandomizerOptions: RandomizerOptions{Ratio: 100},
+			ScanOptions:       ScanOptions{Workers: workers},
+		}
+		items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+		assert.ErrorRaised(t, "Should not raise error", err, false)
+		assert.Equal(t, "Should select all files", summary.totalSelected, 40)
+		ids := _getIndices(items)
+		for i, id := range ids {
+			assert.Equal(t, "Ids should follow walk order", id, i)
+		}
+		if previous != nil {
+			assert.EqualSlice(t, "Order should not depend on workers", ids, previous)
+		}
+		previous = ids
+	}
+}
+
+func TestCollectMediaContentAudioFilter(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"with_audio.mp4":    {Data: mocks.CreateDataWithTracks(10, 640, 360, "avc1", 25, true), Mode: 0755, ModTime: modTime},
+		"without_audio.mp4": {Data: mocks.CreateDataWithTracks(10, 640, 360, "avc1", 25, false), Mode: 0755, ModTime: modTime},
+		"no_tracks.mp4":     {Data: mocks.CreateData(10), Mode: 0755, ModTime: modTime},
+	}
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+		PlayOptions:       PlayOptions{Audio: true},
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should keep files with audio or unknown tracks", len(items), 2)
+	assert.Equal(t, "Should keep unknown tracks", items[0].Name, "no_tracks.mp4")
+	assert.Equal(t, "Should keep file with audio", items[1].Name, "with_audio.mp4")
+	assert.Equal(t, "Should store codec", items[1].Codec, "avc1")
+	assert.Equal(t, "Should store resolution", items[1].Width, 640)
+	assert.Equal(t, "Should count rejected file", summary.rejected[filterNoAudio], 1)
+
+	params.PlayOptions.Audio = false
+	items, _, _ = collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.Equal(t, "Should keep all files without audio option", len(items), 3)
+}
+
+func TestGetDataRejected(t *testing.T) {
+	s := Summarizer{totalScanned: 2, totalSelected: 1, ratio: 100, rejected: map[string]int{filterNoAudio: 1}}
+	var buf bytes.Buffer
+	s.getData(&buf)
+	splits := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "Should report rejected files", splits[11], "Rejected by no_audio filter: 1")
+}
+
+func TestDateFilterFileName(t *testing.T) {
+	params := Params{
+		fdate:         time.Date(2023, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:         time.Date(2024, 3, 26, 0, 0, 0, 0, time.UTC),
+		FilterOptions: FilterOptions{DateSource: DateSourceFileName},
+	}
+	mTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	fd := mocks.CreateFakeDirEntry("vlc-record-2023-06-16-22h13m39s-show.mp4", false, mTime)
+	assert.Equal(t, "Should use date in file name", dateFilter(fd, params), true)
+	fd = mocks.CreateFakeDirEntry("show.mp4", false, mTime)
+	assert.Equal(t, "Should not select file without date in name", dateFilter(fd, params), false)
+}
+
+func TestCollectMediaContentCreationDate(t *testing.T) {
+	copied := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"recorded_in_range.mp4":  {Data: mocks.CreateDataWithCreationTime(10, time.Date(2023, 6, 16, 0, 0, 0, 0, time.UTC)), Mode: 0755, ModTime: copied},
+		"recorded_too_early.mp4": {Data: mocks.CreateDataWithCreationTime(10, time.Date(2021, 6, 16, 0, 0, 0, 0, time.UTC)), Mode: 0755, ModTime: copied},
+		"show-2023-09-01.mp4":    {Data: mocks.CreateData(10), Mode: 0755, ModTime: copied},
+		"undated.mp4":            {Data: mocks.CreateData(10), Mode: 0755, ModTime: copied},
+	}
+	params := Params{
+		fdate:             time.Date(2023, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2024, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+		FilterOptions:     FilterOptions{DateSource: DateSourceMvhdCreation},
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should select only embedded date in range", len(items), 1)
+	assert.Equal(t, "Should select recorded file", items[0].Name, "recorded_in_range.mp4")
+	assert.Equal(t, "Should count date rejections", summary.rejected[filterDate], 3)
+	assert.Equal(t, "Should not count date rejections as scanned", summary.totalScanned, 1)
+	assert.Equal(t, "Should count only scanned folder files", summary.dirs[items[0].Dir].scanned, 1)
+
+	params.FilterOptions.DateSource = DateSourceFirstAvailable
+	items, _, _ = collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.Equal(t, "Should fall back to file name", len(items), 2)
+	assert.Equal(t, "Should select dated file name", items[1].Name, "show-2023-09-01.mp4")
+}
+
+func TestCollectMediaContentRatioAfterCreationDate(t *testing.T) {
+	copied := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i := 0; i < 10; i++ {
+		created := time.Date(2023, 6, 16, 0, 0, 0, 0, time.UTC)
+		if i%2 == 0 {
+			created = time.Date(2021, 6, 16, 0, 0, 0, 0, time.UTC)
+		}
+		fsys["shows/track_"+strconv.Itoa(i)+".mp4"] = &fstest.MapFile{Data: mocks.CreateDataWithCreationTime(10, created), Mode: 0755, ModTime: copied}
+	}
+	params := Params{
+		fdate:             time.Date(2023, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2024, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100, FolderRatios: map[string]uint8{"shows": 50}},
+		FilterOptions:     FilterOptions{DateSource: DateSourceMvhdCreation},
+	}
+	params.RandomizerOptions.normalizeFolderRatios()
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should count only files in date range as scanned", summary.totalScanned, 5)
+	assert.Equal(t, "Should count date rejections", summary.rejected[filterDate], 5)
+	assert.Equal(t, "Should select from scanned files", len(items), summary.totalSelected)
+	assert.Equal(t, "Should count only files in date range per rule", summary.folders["shows"].scanned, 5)
+	assert.Equal(t, "Should select from rule folder", summary.folders["shows"].selected, len(items))
+}
+
+func TestCollectMediaContentPatternFilters(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"2023/holiday/beach.mp4":       {Data: mocks.CreateData(10), Mode: 0755, ModTime: modTime},
+		"2023/holiday/beach-draft.mp4": {Data: mocks.CreateData(10), Mode: 0755, ModTime: modTime},
+		"2023/work/meeting.mp4":        {Data: mocks.CreateData(10), Mode: 0755, ModTime: modTime},
+		"holiday/mountains.mp4":        {Data: mocks.CreateData(10), Mode: 0755, ModTime: modTime},
+	}
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+		FilterOptions: FilterOptions{
+			IncludeF:       []string{"2023"},
+			IncludePattern: []string{"**/holiday/*.mp4"},
+			ExcludePattern: []string{"re:-draft"},
+		},
+	}
+	err := params.FilterOptions.compilePatterns()
+	assert.ErrorRaised(t, "Should compile patterns", err, false)
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should select one file", len(items), 1)
+	assert.Equal(t, "Should select beach", items[0].Name, "beach.mp4")
+	assert.Equal(t, "Should count folder rejections", summary.rejected[filterIncludeFolder], 1)
+	assert.Equal(t, "Should count include pattern rejections", summary.rejected[filterIncludePattern], 1)
+	assert.Equal(t, "Should count exclude pattern rejections", summary.rejected[filterExcludePattern], 1)
+}
+
+func TestCollectMediaContentFolderRatios(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i := 0; i < 5; i++ {
+		fsys["huge/track_"+strconv.Itoa(i)+".mp4"] = &fstest.MapFile{Data: mocks.CreateData(10), Mode: 0755, ModTime: modTime}
+		fsys["huge/favourites/track_"+strconv.Itoa(i)+".mp4"] = &fstest.MapFile{Data: mocks.CreateData(10), Mode: 0755, ModTime: modTime}
+	}
+	params := Params{
+		fdate:       time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:       time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration: math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{
+			Ratio:        100,
+			FolderRatios: map[string]uint8{"huge": 0, "huge/favourites": 100},
+		},
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should only select favourites", len(items), 5)
+	for _, item := range items {
+		assert.Equal(t, "Should select from favourites", strings.Contains(item.AbsPath, "/huge/favourites/"), true)
+	}
+
+	var buf bytes.Buffer
+	summary.getData(&buf)
+	splits := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "Should report rule folder", splits[11], "Folder huge selected: 0 -- required ratio: 0 -- got: 0.00%")
+	assert.Equal(t, "Should report nested rule folder", splits[12], "Folder huge/favourites selected: 5 -- required ratio: 100 -- got: 100.00%")
+}
+
+func TestWritePlayListWithSeedAnnotation(t *testing.T) {
+	var buf bytes.Buffer
+	pl := buildPlayList([]MediaItem{{Location: "/home/Music/track.mp4", Name: "track.mp4"}}, PlayOptions{Audio: true})
+	pl.annotateSeed(1234)
+	writePlayList(pl, &buf)
+	output := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "Output should match", strings.TrimSpace(output[2]), "<title></title>")
+	assert.Equal(t, "Output should hold the seed", strings.TrimSpace(output[3]), "<annotation>Generated by playmix with seed 1234</annotation>")
+}
+
+func TestSeedReproducesPlaylist(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i := 0; i < 60; i++ {
+		fn := "folder_" + strconv.Itoa(i%3) + "/track_" + strconv.Itoa(i) + ".mp4"
+		fsys[fn] = &fstest.MapFile{Data: mocks.CreateData(10 + i), Mode: 0755, ModTime: modTime}
+	}
+	generate := func(seed int64) []int {
+		params := Params{
+			fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+			tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+			maxDuration:       math.MaxInt32,
+			RandomizerOptions: RandomizerOptions{Ratio: 50, Order: OrderSpread, Seed: seed},
+		}
+		rng := params.RandomizerOptions.newRand()
+		items, _, err := collectMediaContent(rng, "/home/Music", fsys, params)
+		assert.ErrorRaised(t, "Should not raise error", err, false)
+		orderPlaylist(rng, items, params.RandomizerOptions)
+		return _getIndices(items)
+	}
+	first := generate(7)
+	assert.EqualSlice(t, "Same seed should give the same playlist", generate(7), first)
+	assert.NotEqualSlice(t, "Another seed should give another playlist", generate(8), first)
+}
+
+func TestGetDataSeed(t *testing.T) {
+	s := Summarizer{totalScanned: 1, totalSelected: 1, ratio: 100, seed: 99}
+	var buf bytes.Buffer
+	s.getData(&buf)
+	assert.Equal(t, "Should print the seed", strings.HasSuffix(buf.String(), "Seed: 99\n"), true)
+}
+
+func TestCollectMediaContentTargetDuration(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i, seconds := range []int{600, 1500, 2400, 900, 3000, 1200, 45} {
+		fsys["track_"+strconv.Itoa(i)+".mp4"] = &fstest.MapFile{Data: mocks.CreateData(seconds), Mode: 0755, ModTime: modTime}
+	}
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100, TargetDuration: 5400, Tolerance: 30},
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should reach target", summary.totalDuration, 5400)
+	assert.Equal(t, "Should count selected items", summary.totalSelected, len(items))
+
+	var buf bytes.Buffer
+	summary.getData(&buf)
+	splits := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "Should report target", splits[11], "Target duration: 5400 sec (+/- 30) -- got: 5400 sec")
+}
+
+func TestCollectMediaContentCount(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i := 0; i < 30; i++ {
+		fsys["track_"+strconv.Itoa(i)+".mp4"] = &fstest.MapFile{Data: mocks.CreateData(10 + i), Mode: 0755, ModTime: modTime}
+	}
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100, Count: 7},
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should select exactly count items", len(items), 7)
+
+	var buf bytes.Buffer
+	summary.getData(&buf)
+	splits := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "Should report count", splits[11], "Target count: 7 -- got: 7")
+}
+
+func TestCollectMediaContentBucketQuota(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{}
+	for i := 0; i < 20; i++ {
+		fsys["short_"+strconv.Itoa(i)+".mp4"] = &fstest.MapFile{Data: mocks.CreateData(5 + i), Mode: 0755, ModTime: modTime}
+	}
+	for i := 0; i < 4; i++ {
+		fsys["long_"+strconv.Itoa(i)+".mp4"] = &fstest.MapFile{Data: mocks.CreateData(300 + i), Mode: 0755, ModTime: modTime}
+	}
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100, BucketQuota: map[string]Percent{"<60": 50, ">180": 50}},
+	}
+	err := params.RandomizerOptions.parseBucketQuota()
+	assert.ErrorRaised(t, "Should parse bucket quota", err, false)
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should balance short and long files", len(items), 8)
+
+	var buf bytes.Buffer
+	summary.getData(&buf)
+	splits := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "Should report short band", splits[11], "Bucket quota <60: 4 -- required share: 50% -- got: 50.00%")
+	assert.Equal(t, "Should report long band", splits[12], "Bucket quota >180: 4 -- required share: 50% -- got: 50.00%")
+}
+
+func TestGetDataBucketQuotaNothingSelected(t *testing.T) {
+	summary := Summarizer{dBucket: newDurationBucket(nil), quotas: []bandResult{{label: "<60", share: 50}}}
+	var buf bytes.Buffer
+	summary.getData(&buf)
+	assert.Equal(t, "Should print zero share instead of NaN", strings.Contains(buf.String(), "Bucket quota <60: 0 -- required share: 50% -- got: 0.00%\n"), true)
+}
+
+func TestCollectMediaContentCountsEveryFolder(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       math.MaxInt32,
+		RandomizerOptions: RandomizerOptions{Ratio: 100, Count: 2},
+	}
+	fsys := fstest.MapFS{
+		"rock/a.mp4": {Data: mocks.CreateData(60), Mode: 0755, ModTime: modTime},
+		"rock/b.mp4": {Data: mocks.CreateData(60), Mode: 0755, ModTime: modTime},
+		"jazz/c.mp4": {Data: mocks.CreateData(60), Mode: 0755, ModTime: modTime},
+	}
+	items, summary, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should keep folder_ratios counts empty", len(summary.folders), 0)
+	assert.Equal(t, "Should count every folder", len(summary.dirs), 2)
+	selected := 0
+	for _, item := range items {
+		assert.Equal(t, "Should count scanned files per item folder", summary.dirs[item.Dir] != nil, true)
+	}
+	for _, d := range summary.dirs {
+		selected += d.selected
+	}
+	assert.Equal(t, "Should count selected files per folder", selected, 2)
+	rock := relativeDir(getPathParts("/home/Music"), filepath.Join("/home/Music", "rock", "a.mp4"))
+	assert.Equal(t, "Should count scanned files", summary.dirs[rock].scanned, 2)
+}
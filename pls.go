@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+type plsExporter struct{}
+
+func (plsExporter) Name() string { return FormatPls }
+
+func (plsExporter) Extension() string { return ".pls" }
+
+// Export writes a version 2 PLS. The format has no place for play options,
+// so they are left out.
+func (plsExporter) Export(content []MediaItem, options ExportOptions, w io.Writer) error {
+	paths, err := exportPaths(content, options.Base)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("[playlist]\n")
+	for i, item := range content {
+		fmt.Fprintf(&b, "File%d=%s\n", i+1, paths[i])
+		fmt.Fprintf(&b, "Title%d=%s\n", i+1, item.Name)
+		fmt.Fprintf(&b, "Length%d=%d\n", i+1, int(math.Round(item.Duration)))
+	}
+	fmt.Fprintf(&b, "NumberOfEntries=%d\n", len(content))
+	b.WriteString("Version=2\n")
+	_, err = io.WriteString(w, b.String())
+	if err != nil {
+		return fmt.Errorf("Error writing pls playlist: %w\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sniffSize is the number of leading bytes handed to Prober.Match,
+// enough to see three transport stream packets.
+const sniffSize = 512
+
+// Prober reads the duration of one container format.
+type Prober interface {
+	// Name identifies the container in logs and errors.
+	Name() string
+	// Extensions lists the file extensions collected for this container.
+	Extensions() []string
+	// Match reports whether the leading bytes of a file belong to this container.
+	Match(header []byte) bool
+	// Probe reads the duration and, where the container allows, the track details.
+	Probe(r io.ReaderAt, size int64) (MediaInfo, error)
+}
+
+// MediaInfo is what a Prober extracts from a file. Track details are left
+// zero by probers reading only the duration, so HasVideo and HasAudio both
+// being false means the tracks are unknown.
+type MediaInfo struct {
+	Duration  float64 `json:"duration"`
+	Width     int     `json:"width,omitempty"`
+	Height    int     `json:"height,omitempty"`
+	Codec     string  `json:"codec,omitempty"`
+	FrameRate float64 `json:"frame_rate,omitempty"`
+	HasVideo  bool    `json:"has_video,omitempty"`
+	HasAudio  bool    `json:"has_audio,omitempty"`
+	// CreationTime is the creation date stored in the container, zero when absent.
+	CreationTime time.Time `json:"creation_time,omitzero"`
+}
+
+func (m MediaInfo) hasTrackInfo() bool {
+	return m.HasVideo || m.HasAudio
+}
+
+type proberRegistry struct {
+	probers []Prober
+}
+
+func newProberRegistry(probers ...Prober) *proberRegistry {
+	return &proberRegistry{probers: probers}
+}
+
+func (r *proberRegistry) register(p Prober) {
+	r.probers = append(r.probers, p)
+}
+
+// sniff returns the first registered prober matching the header.
+func (r *proberRegistry) sniff(header []byte) (Prober, bool) {
+	for _, p := range r.probers {
+		if p.Match(header) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func (r *proberRegistry) isMediaExtension(ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, p := range r.probers {
+		for _, e := range p.Extensions() {
+			if e == ext {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var probers = newProberRegistry(mp4Prober{}, mkvProber{}, aviProber{}, flvProber{}, mpegProber{})
+
+// registerProber adds a prober for a custom container. Its extensions are
+// collected during the walk and files are routed to it by Match. The registry
+// is not locked, so it must only be called from init, before any scan starts.
+func registerProber(p Prober) {
+	probers.register(p)
+}
+
+// probe runs the prober, turning a panic on corrupt input
+// (e.g. inside a third party parser) into an error.
+func probe(p Prober, r io.ReaderAt, size int64) (info MediaInfo, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			info, err = MediaInfo{}, fmt.Errorf("Corrupt %s data: %v", p.Name(), rec)
+		}
+	}()
+	return p.Probe(r, size)
+}
+
+// candidate is a file selected during the walk, waiting to be probed.
+type candidate struct {
+	id      int
+	path    string
+	absPath string
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+type probeResult struct {
+	info MediaInfo
+	err  error
+}
+
+// errProbeSkipped marks candidates left unprobed after another candidate failed
+var errProbeSkipped = errors.New("Not probed after an earlier failure")
+
+// probeCandidates runs getMediaInfo on the pending candidates with the given number
+// of workers, storing the outcome in results at the candidate's index. With stopOnError
+// the remaining candidates are not probed after the first failure and get errProbeSkipped.
+func probeCandidates(fsys fs.FS, candidates []candidate, pending []int, results []probeResult, workers int, stopOnError bool) {
+	jobs := make(chan int)
+	var processed atomic.Int64
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if stopOnError && failed.Load() {
+					results[i] = probeResult{err: errProbeSkipped}
+					continue
+				}
+				info, err := getMediaInfo(fsys, candidates[i].path)
+				results[i] = probeResult{info: info, err: err}
+				if err != nil {
+					failed.Store(true)
+				}
+				if n := processed.Add(1); n%500 == 0 {
+					fmt.Printf("Processed %d/%d files\n", n, len(pending))
+				}
+			}
+		}()
+	}
+	for _, i := range pending {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
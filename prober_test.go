@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type fakeProber struct{}
+
+func (fakeProber) Name() string { return "fake" }
+
+func (fakeProber) Extensions() []string { return []string{".fake"} }
+
+func (fakeProber) Match(header []byte) bool { return bytes.HasPrefix(header, []byte("FAKE")) }
+
+func (fakeProber) Probe(r io.ReaderAt, size int64) (MediaInfo, error) {
+	if size < 8 {
+		return MediaInfo{}, fmt.Errorf("too short")
+	}
+	return MediaInfo{Duration: float64(size)}, nil
+}
+
+func TestProberRegistrySniff(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{mocks.CreateData(10), "mp4"},
+		{mocks.CreateMkvData(10, "webm", 1000000), "matroska"},
+		{mocks.CreateAviData(10, 0), "avi"},
+		{mocks.CreateFlvData(10, 1000), "flv"},
+		{mocks.CreateTsData(3, 0), "mpeg"},
+		{mocks.CreatePsData(3, 0), "mpeg"},
+	}
+	for _, tt := range tests {
+		p, found := probers.sniff(tt.data[:min(len(tt.data), sniffSize)])
+		assert.Equal(t, "Should find prober for "+tt.expected, found, true)
+		assert.Equal(t, "Should match container", p.Name(), tt.expected)
+	}
+}
+
+func TestProberRegistrySniffUnknown(t *testing.T) {
+	_, found := probers.sniff([]byte("plain text file"))
+	assert.Equal(t, "Should not match anything", found, false)
+}
+
+func TestProberRegistryIsMediaExtension(t *testing.T) {
+	r := newProberRegistry(mp4Prober{}, fakeProber{})
+	assert.Equal(t, "Should know .mp4", r.isMediaExtension(".mp4"), true)
+	assert.Equal(t, "Should be case insensitive", r.isMediaExtension(".MP4"), true)
+	assert.Equal(t, "Should know registered extension", r.isMediaExtension(".fake"), true)
+	assert.Equal(t, "Should not know .mkv", r.isMediaExtension(".mkv"), false)
+}
+
+func TestGetDurationMisnamedFile(t *testing.T) {
+	fn := "actually_matroska.mp4"
+	f := fstest.MapFS{
+		fn: {
+			Data:    mocks.CreateMkvData(33, "matroska", 1000000),
+			Mode:    0755,
+			ModTime: time.Now(),
+		},
+	}
+	duration, err := getDuration(f, fn)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should probe by content", duration, 33)
+}
+
+func TestGetDurationEmptyFile(t *testing.T) {
+	fn := "empty.mp4"
+	f := fstest.MapFS{fn: {Data: []byte{}, Mode: 0755, ModTime: time.Now()}}
+	_, err := getDuration(f, fn)
+	assert.ErrorRaised(t, "Should raise error for empty file", err, true)
+}
+
+func TestRegisterProber(t *testing.T) {
+	defer func(r *proberRegistry) { probers = r }(probers)
+	probers = newProberRegistry(probers.probers...)
+	registerProber(fakeProber{})
+
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       1000,
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+	}
+	fsys := fstest.MapFS{
+		"custom.fake": {
+			Data:    []byte("FAKE-CONTAINER"),
+			Mode:    0755,
+			ModTime: modTime,
+		},
+	}
+	items, _, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should collect custom container", len(items), 1)
+	assert.Equal(t, "Should use custom prober", items[0].Duration, 14)
+}
+
+func TestProbeRecoversPanic(t *testing.T) {
+	// moov holding an empty mvhd box makes the mp4 parser index an empty slice
+	data := []byte{0, 0, 0, 16, 'm', 'o', 'o', 'v', 0, 0, 0, 8, 'm', 'v', 'h', 'd'}
+	_, err := probe(mp4Prober{}, bytes.NewReader(data), int64(len(data)))
+	assert.ErrorRaised(t, "Should turn panic into error", err, true)
+}
+
+func TestProbeCandidatesKeepsOrder(t *testing.T) {
+	fsys := fstest.MapFS{}
+	var candidates []candidate
+	for i := 0; i < 50; i++ {
+		fn := fmt.Sprintf("track_%02d.mp4", i)
+		fsys[fn] = &fstest.MapFile{Data: mocks.CreateData(i + 1), Mode: 0755, ModTime: time.Now()}
+		candidates = append(candidates, candidate{id: i, path: fn})
+	}
+	results := make([]probeResult, len(candidates))
+	pending := []int{}
+	for i := range candidates {
+		pending = append(pending, i)
+	}
+	probeCandidates(fsys, candidates, pending, results, 8, true)
+	for i, r := range results {
+		assert.ErrorRaised(t, "Should not raise error", r.err, false)
+		assert.Equal(t, "Result should match candidate", r.info.Duration, float64(i+1))
+	}
+}
+
+func TestProbeCandidatesRecordsErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"good.mp4": {Data: mocks.CreateData(10), Mode: 0755, ModTime: time.Now()},
+		"bad.mp4":  {Data: []byte("garbage"), Mode: 0755, ModTime: time.Now()},
+	}
+	candidates := []candidate{{id: 0, path: "bad.mp4"}, {id: 1, path: "good.mp4"}}
+	results := make([]probeResult, len(candidates))
+	probeCandidates(fsys, candidates, []int{0, 1}, results, 1, false)
+	assert.ErrorRaised(t, "Should keep error of bad file", results[0].err, true)
+	assert.Equal(t, "Should still probe good file", results[1].info.Duration, 10)
+}
+
+func TestProbeCandidatesOnlyPending(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.mp4": {Data: mocks.CreateData(10), Mode: 0755, ModTime: time.Now()},
+		"b.mp4": {Data: mocks.CreateData(20), Mode: 0755, ModTime: time.Now()},
+	}
+	candidates := []candidate{{id: 0, path: "a.mp4"}, {id: 1, path: "b.mp4"}}
+	results := []probeResult{{info: MediaInfo{Duration: 99}}, {}}
+	probeCandidates(fsys, candidates, []int{1}, results, 2, true)
+	assert.Equal(t, "Should keep prefilled result", results[0].info.Duration, 99)
+	assert.Equal(t, "Should probe pending candidate", results[1].info.Duration, 20)
+}
+
+func TestProbeCandidatesMarksSkipped(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bad.mp4":  {Data: []byte("garbage"), Mode: 0755, ModTime: time.Now()},
+		"good.mp4": {Data: mocks.CreateData(10), Mode: 0755, ModTime: time.Now()},
+	}
+	candidates := []candidate{{id: 0, path: "bad.mp4"}, {id: 1, path: "good.mp4"}}
+	results := make([]probeResult, len(candidates))
+	probeCandidates(fsys, candidates, []int{0, 1}, results, 1, true)
+	assert.ErrorRaised(t, "Should keep error of bad file", results[0].err, true)
+	assert.Equal(t, "Should mark unprobed candidate", errors.Is(results[1].err, errProbeSkipped), true)
+}
+
+func TestCollectMediaContentAbortStoresNothing(t *testing.T) {
+	modTime := time.Date(2020, 3, 26, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"a_good.mp4": {Data: mocks.CreateData(10), Mode: 0755, ModTime: modTime},
+		"b_bad.mp4":  {Data: []byte("garbage"), Mode: 0755, ModTime: modTime},
+		"c_good.mp4": {Data: mocks.CreateData(20), Mode: 0755, ModTime: modTime},
+	}
+	params := Params{
+		fdate:             time.Date(2000, 3, 26, 0, 0, 0, 0, time.UTC),
+		tdate:             time.Date(2030, 3, 26, 0, 0, 0, 0, time.UTC),
+		maxDuration:       1000,
+		RandomizerOptions: RandomizerOptions{Ratio: 100},
+		ScanOptions:       ScanOptions{Workers: 1},
+		index:             newMediaIndex("/home/Music"),
+	}
+	_, _, err := collectMediaContent(_newRand(), "/home/Music", fsys, params)
+	assert.ErrorRaised(t, "Should raise probe error", err, true)
+	assert.Equal(t, "Should report the real failure", errors.Is(err, errProbeSkipped), false)
+	assert.Equal(t, "Should not store entries before failing", len(params.index.Entries), 0)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+// reportVersion is bumped whenever a field of Report changes meaning or is removed
+const reportVersion = 1
+
+// Report is the machine-readable form of the summary
+type Report struct {
+	Version        int            `json:"version"`
+	GeneratedAt    time.Time      `json:"generated_at"`
+	Seed           int64          `json:"seed"`
+	Scanned        int            `json:"scanned"`
+	Selected       int            `json:"selected"`
+	RequiredRatio  uint8          `json:"required_ratio"`
+	ActualRatio    float64        `json:"actual_ratio"`
+	TotalDuration  float64        `json:"total_duration"`
+	TargetDuration int            `json:"target_duration,omitempty"`
+	Tolerance      int            `json:"tolerance,omitempty"`
+	Count          int            `json:"count,omitempty"`
+	Buckets        []ReportBucket `json:"buckets"`
+	Quotas         []ReportQuota  `json:"quotas,omitempty"`
+	Folders        []ReportFolder `json:"folders,omitempty"`
+	Directories    []ReportDir    `json:"directories,omitempty"`
+	Cached         int            `json:"cached"`
+	Rejected       map[string]int `json:"rejected,omitempty"`
+	Skipped        []SkippedFile  `json:"skipped,omitempty"`
+	Timings        []ReportTiming `json:"timings,omitempty"`
+}
+
+type ReportBucket struct {
+	Label    string  `json:"label"`
+	Count    int     `json:"count"`
+	Percent  float64 `json:"percent"`
+	Duration float64 `json:"duration"`
+}
+
+type ReportQuota struct {
+	Band          string  `json:"band"`
+	Selected      int     `json:"selected"`
+	RequiredShare float64 `json:"required_share"`
+	ActualShare   float64 `json:"actual_share"`
+}
+
+type ReportFolder struct {
+	Folder        string  `json:"folder"`
+	Scanned       int     `json:"scanned"`
+	Selected      int     `json:"selected"`
+	RequiredRatio uint8   `json:"required_ratio"`
+	ActualRatio   float64 `json:"actual_ratio"`
+}
+
+// ReportDir counts the files of every folder holding media, with or without a ratio rule
+type ReportDir struct {
+	Dir         string  `json:"dir"`
+	Scanned     int     `json:"scanned"`
+	Selected    int     `json:"selected"`
+	ActualRatio float64 `json:"actual_ratio"`
+}
+
+type ReportTiming struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+}
+
+// percent returns part of whole in percent, 0 for an empty whole
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
+
+func (s Summarizer) report() Report {
+	r := Report{
+		Version:        reportVersion,
+		GeneratedAt:    time.Now().UTC(),
+		Seed:           s.seed,
+		Scanned:        s.totalScanned,
+		Selected:       s.totalSelected,
+		RequiredRatio:  s.ratio,
+		ActualRatio:    percent(s.totalSelected, s.totalScanned),
+		TotalDuration:  s.totalDuration,
+		TargetDuration: s.targetDuration,
+		Tolerance:      s.tolerance,
+		Count:          s.count,
+		Cached:         s.cached,
+		Rejected:       s.rejected,
+		Skipped:        s.skipped,
+		Timings:        s.timings,
+	}
+	d := s.dBucket
+	d.init()
+	for i, n := range d.counts {
+		r.Buckets = append(r.Buckets, ReportBucket{Label: d.label(i), Count: n, Percent: percent(n, d.total()), Duration: d.durations[i]})
+	}
+	for _, q := range s.quotas {
+		r.Quotas = append(r.Quotas, ReportQuota{Band: q.label, Selected: q.selected, RequiredShare: q.share, ActualShare: percent(q.selected, s.totalSelected)})
+	}
+	for _, folder := range s.sortedFolders() {
+		f := s.folders[folder]
+		r.Folders = append(r.Folders, ReportFolder{Folder: folder, Scanned: f.scanned, Selected: f.selected, RequiredRatio: f.requested, ActualRatio: percent(f.selected, f.scanned)})
+	}
+	for _, dir := range slices.Sorted(maps.Keys(s.dirs)) {
+		d := s.dirs[dir]
+		r.Directories = append(r.Directories, ReportDir{Dir: dir, Scanned: d.scanned, Selected: d.selected, ActualRatio: percent(d.selected, d.scanned)})
+	}
+	return r
+}
+
+func writeReport(r Report, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(r)
+	if err != nil {
+		return fmt.Errorf("Error writing report: %w\n", err)
+	}
+	return nil
+}
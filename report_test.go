@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"playmix/internal/assert"
+	"playmix/internal/mocks"
+	"testing"
+	"time"
+)
+
+func _createSummary() Summarizer {
+	s := Summarizer{
+		ratio:         50,
+		seed:          42,
+		totalScanned:  4,
+		totalSelected: 2,
+		totalDuration: 90,
+		dBucket:       newDurationBucket([]float64{60}),
+		cached:        1,
+		rejected:      map[string]int{filterDate: 3},
+		skipped:       []SkippedFile{{Path: "bad.mp4", Reason: "Moov box not found"}},
+	}
+	for _, duration := range []float64{30, 60, 90, 120} {
+		s.dBucket.allocate(duration)
+	}
+	s.scanFolder("favourites", 100)
+	s.selectFolder("favourites")
+	s.scanDir("Music/rock")
+	s.scanDir("Music/rock")
+	s.scanDir("Music/jazz")
+	s.selectDir("Music/rock")
+	s.addTiming("collect", 1500*time.Millisecond)
+	return s
+}
+
+func TestSummarizerReport(t *testing.T) {
+	r := _createSummary().report()
+	assert.Equal(t, "Should set schema version", r.Version, reportVersion)
+	assert.Equal(t, "Should set seed", r.Seed, 42)
+	assert.Equal(t, "Should compute actual ratio", r.ActualRatio, 50)
+	assert.Equal(t, "Should have one entry per bucket", len(r.Buckets), 2)
+	assert.Equal(t, "Should label bucket", r.Buckets[1].Label, "60<")
+	assert.Equal(t, "Should count bucket", r.Buckets[1].Count, 3)
+	assert.Equal(t, "Should sum bucket duration", r.Buckets[1].Duration, 270)
+	assert.Equal(t, "Should compute bucket percent", r.Buckets[0].Percent, 25)
+	assert.Equal(t, "Should list folder", r.Folders[0].Folder, "favourites")
+	assert.Equal(t, "Should compute folder ratio", r.Folders[0].ActualRatio, 100)
+	assert.Equal(t, "Should list every folder", len(r.Directories), 2)
+	assert.Equal(t, "Should sort folders", r.Directories[1].Dir, "Music/rock")
+	assert.Equal(t, "Should count scanned files", r.Directories[1].Scanned, 2)
+	assert.Equal(t, "Should compute folder ratio", r.Directories[1].ActualRatio, 50)
+	assert.Equal(t, "Should keep unselected folder", r.Directories[0].Selected, 0)
+	assert.Equal(t, "Should list skipped files", r.Skipped[0].Path, "bad.mp4")
+	assert.Equal(t, "Should list timings", r.Timings[0].Seconds, 1.5)
+}
+
+func TestSummarizerReportEmptyScan(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeReport(Summarizer{}.report(), &buf)
+	assert.ErrorRaised(t, "Should write report of an empty scan", err, false)
+}
+
+func TestWriteReport(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeReport(_createSummary().report(), &buf)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+
+	var decoded map[string]any
+	err = json.Unmarshal(buf.Bytes(), &decoded)
+	assert.ErrorRaised(t, "Should write valid json", err, false)
+	assert.Equal(t, "Should write schema version", decoded["version"].(float64), reportVersion)
+	assert.Equal(t, "Should write scanned", decoded["scanned"].(float64), 4)
+	assert.Equal(t, "Should write rejected counts", decoded["rejected"].(map[string]any)["date"].(float64), 3)
+
+	err = writeReport(_createSummary().report(), mocks.FakeWriter{})
+	assert.ErrorRaised(t, "Should raise write error", err, true)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// selectByDuration picks the items whose summed duration lands closest to
+// target without going over it by more than tolerance. It is a subset sum over
+// whole seconds: reachedBy[s] keeps the item that first made the sum s
+// reachable, so following it back gives the items of the sum. Items are
+// shuffled first so that the seed decides between equally good subsets.
+// The picked items keep their original order. A warning is logged when the
+// closest sum is still more than tolerance away from the target.
+func selectByDuration(rng *rand.Rand, items []MediaItem, target, tolerance int) []MediaItem {
+	limit := target + tolerance
+	order := rng.Perm(len(items))
+	weights := make([]int, len(items))
+	reachedBy := make([]int, limit+1)
+	for s := range reachedBy {
+		reachedBy[s] = -1
+	}
+	for _, i := range order {
+		weights[i] = max(1, int(math.Round(items[i].Duration)))
+		for s := limit; s >= weights[i]; s-- {
+			if reachedBy[s] == -1 && (s == weights[i] || reachedBy[s-weights[i]] != -1) {
+				reachedBy[s] = i
+			}
+		}
+	}
+
+	best := -1
+	for s := 1; s <= limit; s++ {
+		if reachedBy[s] != -1 && (best == -1 || abs(s-target) <= abs(best-target)) {
+			best = s
+		}
+	}
+	if best == -1 {
+		log.Printf("No files fit in the target duration of %ds, the playlist is empty\n", target)
+	} else if target-best > tolerance {
+		log.Printf("Selected files reach %ds, short of the target duration of %ds\n", best, target)
+	}
+	picked := make([]bool, len(items))
+	for s := best; s > 0; s -= weights[reachedBy[s]] {
+		picked[reachedBy[s]] = true
+	}
+	var selected []MediaItem
+	for i, item := range items {
+		if picked[i] {
+			selected = append(selected, item)
+		}
+	}
+	return selected
+}
+
+// bandResult is the outcome of one bucket quota band
+type bandResult struct {
+	label    string
+	share    float64
+	selected int
+}
+
+// selectByQuota fills each band to its share of the playlist. The playlist is
+// as large as the bands allow: a band holding 10 files with a 20% share limits
+// it to 50 files, while a band matching no files is left out of the limit.
+// count, when set, caps it further. Files outside every band
+// are left out. The picked items keep their original order.
+func selectByQuota(rng *rand.Rand, items []MediaItem, bands []durationBand, count int) ([]MediaItem, []bandResult) {
+	members := make([][]int, len(bands))
+	for i, item := range items {
+		for b, band := range bands {
+			if band.contains(item.Duration) {
+				members[b] = append(members[b], i)
+				break
+			}
+		}
+	}
+	total := len(items)
+	for b, band := range bands {
+		if band.share > 0 && len(members[b]) == 0 {
+			log.Printf("Bucket quota %s matches no files, it does not limit the playlist\n", band.label)
+			continue
+		}
+		if band.share > 0 {
+			total = min(total, int(float64(len(members[b]))*100/band.share))
+		}
+	}
+	if count > 0 {
+		total = min(total, count)
+	}
+
+	picked := make([]bool, len(items))
+	results := make([]bandResult, len(bands))
+	for b, band := range bands {
+		n := min(len(members[b]), int(math.Round(float64(total)*band.share/100)))
+		rng.Shuffle(len(members[b]), func(i, j int) {
+			members[b][i], members[b][j] = members[b][j], members[b][i]
+		})
+		for _, i := range members[b][:n] {
+			picked[i] = true
+		}
+		results[b] = bandResult{label: band.label, share: band.share, selected: n}
+	}
+	var selected []MediaItem
+	for i, item := range items {
+		if picked[i] {
+			selected = append(selected, item)
+		}
+	}
+	return selected, results
+}
+
+// reservoir keeps a uniform random sample of at most size items out of
+// every item added, without holding the ones left out. collectMediaContent
+// feeds it each probed file as it passes the filters, so the media items kept
+// are bounded by count. The walk candidates and their probe results are still
+// held for the whole library, as probing runs on all of them up front.
+type reservoir struct {
+	rng   *rand.Rand
+	size  int
+	seen  int
+	items []MediaItem
+}
+
+func newReservoir(rng *rand.Rand, size int) *reservoir {
+	return &reservoir{rng: rng, size: size}
+}
+
+// add keeps the n-th item with probability size/n, replacing a random kept one
+func (r *reservoir) add(item MediaItem) {
+	r.seen++
+	if len(r.items) < r.size {
+		r.items = append(r.items, item)
+		return
+	}
+	if j := r.rng.Intn(r.seen); j < r.size {
+		r.items[j] = item
+	}
+}
+
+// sample returns the kept items in the order they were added
+func (r *reservoir) sample() []MediaItem {
+	sort.Slice(r.items, func(i, j int) bool {
+		return r.items[i].Id < r.items[j].Id
+	})
+	return r.items
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"math/rand"
+	"playmix/internal/assert"
+	"strconv"
+	"testing"
+)
+
+func _createDurationItems(durations ...float64) []MediaItem {
+	var items []MediaItem
+	for i, d := range durations {
+		items = append(items, MediaItem{Name: "track_" + strconv.Itoa(i) + ".mp4", Id: i, Duration: d})
+	}
+	return items
+}
+
+func _totalDuration(items []MediaItem) (total float64) {
+	for _, item := range items {
+		total += item.Duration
+	}
+	return
+}
+
+func TestSelectByDurationExactFit(t *testing.T) {
+	items := _createDurationItems(600, 1500, 2400, 900, 3000, 1200)
+	selected := selectByDuration(_newRand(), items, 5400, 0)
+	assert.Equal(t, "Should reach the target exactly", _totalDuration(selected), 5400)
+	ids := _getIndices(selected)
+	for i := 1; i < len(ids); i++ {
+		assert.Equal(t, "Should keep original order", ids[i] > ids[i-1], true)
+	}
+}
+
+func TestSelectByDurationWithinTolerance(t *testing.T) {
+	items := _createDurationItems(700, 700, 700, 700, 700, 700, 700, 700, 700)
+	selected := selectByDuration(_newRand(), items, 5400, 300)
+	// 7 items give 4900, 8 give 5600: the latter is closer and within tolerance
+	assert.Equal(t, "Should pick the closest sum within tolerance", _totalDuration(selected), 5600)
+
+	selected = selectByDuration(_newRand(), items, 5400, 100)
+	assert.Equal(t, "Should stay under target when overshoot exceeds tolerance", _totalDuration(selected), 4900)
+}
+
+func TestSelectByDurationNotEnoughContent(t *testing.T) {
+	items := _createDurationItems(60, 120, 180)
+	selected := selectByDuration(_newRand(), items, 5400, 60)
+	assert.Equal(t, "Should select everything", len(selected), 3)
+}
+
+func TestSelectByDurationEmpty(t *testing.T) {
+	selected := selectByDuration(_newRand(), nil, 5400, 60)
+	assert.Equal(t, "Should select nothing", len(selected), 0)
+}
+
+func TestSelectByDurationItemsTooLong(t *testing.T) {
+	items := _createDurationItems(7200)
+	selected := selectByDuration(_newRand(), items, 5400, 120)
+	assert.Equal(t, "Should select nothing when every item is over the limit", len(selected), 0)
+}
+
+func TestSelectByDurationDependsOnSeed(t *testing.T) {
+	var durations []float64
+	for i := 0; i < 40; i++ {
+		durations = append(durations, 300)
+	}
+	items := _createDurationItems(durations...)
+	first := _getIndices(selectByDuration(_newRand(), items, 3000, 0))
+	assert.Equal(t, "Should select ten items", len(first), 10)
+	other := _getIndices(selectByDuration(rand.New(rand.NewSource(1)), items, 3000, 0))
+	assert.NotEqualSlice(t, "Another seed should pick other items", other, first)
+}
+
+func TestReservoirKeepsSize(t *testing.T) {
+	r := newReservoir(_newRand(), 5)
+	for _, item := range _createDurationItems(1, 2, 3) {
+		r.add(item)
+	}
+	assert.Equal(t, "Should keep all items when fewer than size", len(r.sample()), 3)
+
+	r = newReservoir(_newRand(), 5)
+	for _, item := range _createDurationItems(make([]float64, 100)...) {
+		r.add(item)
+	}
+	sample := r.sample()
+	assert.Equal(t, "Should keep exactly size items", len(sample), 5)
+	for i := 1; i < len(sample); i++ {
+		assert.Equal(t, "Should keep original order", sample[i].Id > sample[i-1].Id, true)
+	}
+}
+
+func TestReservoirIsUniform(t *testing.T) {
+	rng := _newRand()
+	items := _createDurationItems(make([]float64, 10)...)
+	picked := make([]int, len(items))
+	for run := 0; run < 10000; run++ {
+		r := newReservoir(rng, 3)
+		for _, item := range items {
+			r.add(item)
+		}
+		for _, item := range r.sample() {
+			picked[item.Id]++
+		}
+	}
+	// every item is expected 3000 times
+	for id, n := range picked {
+		assert.Equal(t, "Item "+strconv.Itoa(id)+" should be picked evenly", n > 2800 && n < 3200, true)
+	}
+}
+
+func _quotaBands(t *testing.T, quota map[string]Percent) []durationBand {
+	opts := RandomizerOptions{BucketQuota: quota}
+	err := opts.parseBucketQuota()
+	assert.ErrorRaised(t, "Should parse bucket quota", err, false)
+	return opts.bands
+}
+
+func TestSelectByQuota(t *testing.T) {
+	var durations []float64
+	for i := 0; i < 40; i++ {
+		durations = append(durations, 10) // short
+	}
+	for i := 0; i < 30; i++ {
+		durations = append(durations, 100) // medium
+	}
+	for i := 0; i < 5; i++ {
+		durations = append(durations, 600) // long
+	}
+	items := _createDurationItems(durations...)
+	bands := _quotaBands(t, map[string]Percent{"0-30": 20, "30-180": 60, ">180": 20})
+	selected, results := selectByQuota(_newRand(), items, bands, 0)
+	// 5 long files at 20% limit the playlist to 25 files
+	assert.Equal(t, "Should size playlist by the scarcest band", len(selected), 25)
+	assert.Equal(t, "Short band should get its share", results[0].selected, 5)
+	assert.Equal(t, "Medium band should get its share", results[1].selected, 15)
+	assert.Equal(t, "Long band should get its share", results[2].selected, 5)
+	ids := _getIndices(selected)
+	for i := 1; i < len(ids); i++ {
+		assert.Equal(t, "Should keep original order", ids[i] > ids[i-1], true)
+	}
+
+	selected, results = selectByQuota(_newRand(), items, bands, 10)
+	assert.Equal(t, "Count should cap the playlist", len(selected), 10)
+	assert.Equal(t, "Medium band should get its share of the count", results[1].selected, 6)
+}
+
+func TestSelectByQuotaLeavesOutUnbandedFiles(t *testing.T) {
+	items := _createDurationItems(10, 20, 500, 600)
+	bands := _quotaBands(t, map[string]Percent{"<60": 100})
+	selected, _ := selectByQuota(_newRand(), items, bands, 0)
+	assert.EqualSlice(t, "Should only select files in a band", _getIndices(selected), []int{0, 1})
+}
+
+func TestSelectByQuotaEmptyBand(t *testing.T) {
+	items := _createDurationItems(10, 20, 30, 100, 120)
+	bands := _quotaBands(t, map[string]Percent{"<60": 50, "60-180": 30, ">180": 20})
+	selected, results := selectByQuota(_newRand(), items, bands, 0)
+	assert.Equal(t, "Empty band should not empty the playlist", len(selected), 5)
+	for _, r := range results {
+		if r.label == ">180" {
+			assert.Equal(t, "Empty band should select nothing", r.selected, 0)
+		}
+	}
+}
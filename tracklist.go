@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// isTrackListFormat reports whether the format lists tracks for other tools
+// instead of being a playlist a player can open
+func isTrackListFormat(format string) bool {
+	return format == FormatJsonl || format == FormatCsv
+}
+
+// trackRecord is one track of the final order in the JSON Lines and CSV exports.
+// Index matches the vlc:id of the track in the XSPF playlist.
+type trackRecord struct {
+	Index    int      `json:"index"`
+	Path     string   `json:"path"`
+	Folder   string   `json:"folder"`
+	Duration float64  `json:"duration"`
+	Options  []string `json:"options"`
+	Location string   `json:"location"`
+}
+
+// trackRecords resolves the records of the items in their playlist order
+func trackRecords(content []MediaItem, options ExportOptions) ([]trackRecord, error) {
+	locations, err := exportPaths(content, options.Base)
+	if err != nil {
+		return nil, err
+	}
+	var records []trackRecord
+	for i, item := range content {
+		trackOptions := itemOptions(item, options.PlayOptions)
+		if trackOptions == nil {
+			trackOptions = []string{}
+		}
+		relPath := filepath.ToSlash(item.RelPath)
+		records = append(records, trackRecord{
+			Index:    i,
+			Path:     relPath,
+			Folder:   path.Dir(relPath),
+			Duration: item.Duration,
+			Options:  trackOptions,
+			Location: locations[i],
+		})
+	}
+	return records, nil
+}
+
+type jsonlExporter struct{}
+
+func (jsonlExporter) Name() string { return FormatJsonl }
+
+func (jsonlExporter) Extension() string { return ".jsonl" }
+
+// Export writes one JSON object per track
+func (jsonlExporter) Export(content []MediaItem, options ExportOptions, w io.Writer) error {
+	records, err := trackRecords(content, options)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	for _, r := range records {
+		err = encoder.Encode(r)
+		if err != nil {
+			return fmt.Errorf("Error writing json lines: %w\n", err)
+		}
+	}
+	return nil
+}
+
+var csvHeader = []string{"index", "path", "folder", "duration", "options", "location"}
+
+type csvExporter struct{}
+
+func (csvExporter) Name() string { return FormatCsv }
+
+func (csvExporter) Extension() string { return ".csv" }
+
+// Export writes a header row and one row per track, the options of a track
+// separated by semicolons
+func (csvExporter) Export(content []MediaItem, options ExportOptions, w io.Writer) error {
+	records, err := trackRecords(content, options)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	rows := [][]string{csvHeader}
+	for _, r := range records {
+		rows = append(rows, []string{
+			strconv.Itoa(r.Index),
+			r.Path,
+			r.Folder,
+			strconv.FormatFloat(r.Duration, 'f', -1, 64),
+			strings.Join(r.Options, ";"),
+			r.Location,
+		})
+	}
+	err = writer.WriteAll(rows)
+	if err != nil {
+		return fmt.Errorf("Error writing csv: %w\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"playmix/internal/assert"
+	"strings"
+	"testing"
+)
+
+func TestTrackRecords(t *testing.T) {
+	records, err := trackRecords(_createExportItems(), ExportOptions{PlayOptions: PlayOptions{Audio: true}, Base: "/home/Music"})
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should create record per item", len(records), 2)
+	assert.Equal(t, "Should keep order", records[1].Index, 1)
+	assert.Equal(t, "Should use relative path", records[0].Path, "rock/a b.mp4")
+	assert.Equal(t, "Should derive folder", records[1].Folder, "jazz")
+	assert.Equal(t, "Should keep exact duration", records[0].Duration, 64.6)
+	assert.Equal(t, "Should write location relative to base", records[1].Location, "jazz/c.mp4")
+	assert.EqualSlice(t, "Should have empty options", records[0].Options, []string{})
+}
+
+func TestJsonlExporterDecodes(t *testing.T) {
+	var buf bytes.Buffer
+	err := jsonlExporter{}.Export(_createExportItems(), ExportOptions{}, &buf)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, "Should write line per track", len(lines), 2)
+	var record trackRecord
+	err = json.Unmarshal([]byte(lines[1]), &record)
+	assert.ErrorRaised(t, "Should write valid json per line", err, false)
+	assert.Equal(t, "Should decode path", record.Path, "jazz/c.mp4")
+	assert.EqualSlice(t, "Should decode options", record.Options, []string{"no-audio"})
+}
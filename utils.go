@@ -9,10 +9,30 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 )
 
+// fileNamePattern matches dates like 2023-06-16 or 20230616 in file names, with
+// the optional time VLC appends to recordings (vlc-record-2023-06-16-22h13m39s-...)
+var fileNamePattern = regexp.MustCompile(`(?:^|\D)(\d{4})-?(\d{2})-?(\d{2})(?:-(\d{2})h(\d{2})m(\d{2})s)?(?:\D|$)`)
+
+// fileNameDate returns the first valid date found in the file name
+func fileNameDate(name string) (time.Time, bool) {
+	for _, m := range fileNamePattern.FindAllStringSubmatch(name, -1) {
+		value, layout := m[1]+m[2]+m[3], "20060102"
+		if m[4] != "" {
+			value, layout = value+m[4]+m[5]+m[6], "20060102150405"
+		}
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
 func readInOptFile(fsys fs.FS, fn string) ([]byte, error) {
 	file, err := fsys.Open(fn)
 	if err != nil {
@@ -33,9 +53,10 @@ func getPathParts(p string) []string {
 	return parts
 }
 
-func TimeTrack(start time.Time, name string) {
+func TimeTrack(start time.Time, name string) time.Duration {
 	elapsed := time.Since(start)
 	log.Printf("%s took %s", name, elapsed)
+	return elapsed
 }
 
 func dumpConsole(s any) {
@@ -44,12 +65,7 @@ func dumpConsole(s any) {
 }
 
 func isMediaFile(ext string) bool {
-	for _, v := range mediaExtensions {
-		if v == ext {
-			return true
-		}
-	}
-	return false
+	return probers.isMediaExtension(ext)
 }
 
 func getUrlEncodedPath(path string) string {
@@ -118,3 +118,22 @@ func TestCreateFile(t *testing.T) {
 	file.Close()
 	os.Remove(fileName)
 }
+
+func TestFileNameDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected time.Time
+		found    bool
+	}{
+		{"vlc-record-2023-06-16-22h13m39s-movie.mp4", time.Date(2023, 6, 16, 22, 13, 39, 0, time.UTC), true},
+		{"holiday_2021-12-24.mkv", time.Date(2021, 12, 24, 0, 0, 0, 0, time.UTC), true},
+		{"IMG_20190301_1200.mp4", time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"track_2023-13-45.mp4", time.Time{}, false},
+		{"no_date_here.mp4", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		date, found := fileNameDate(tt.name)
+		assert.Equal(t, "Should find date in "+tt.name, found, tt.found)
+		assert.Equal(t, "Should parse date of "+tt.name, date, tt.expected)
+	}
+}
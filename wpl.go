@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const wplHeader = `<?wpl version="1.0"?>` + "\n"
+
+type wplPlayList struct {
+	XMLName xml.Name `xml:"smil"`
+	Head    wplHead  `xml:"head"`
+	Media   []wplSrc `xml:"body>seq>media"`
+}
+
+type wplHead struct {
+	Meta  []wplMeta `xml:"meta"`
+	Title string    `xml:"title"`
+}
+
+type wplMeta struct {
+	Name    string `xml:"name,attr"`
+	Content string `xml:"content,attr"`
+}
+
+type wplSrc struct {
+	Src string `xml:"src,attr"`
+}
+
+type wplExporter struct{}
+
+func (wplExporter) Name() string { return FormatWpl }
+
+func (wplExporter) Extension() string { return ".wpl" }
+
+// Export writes a Windows Media Player playlist. WPL only lists the media,
+// so titles, durations and play options are left out.
+func (wplExporter) Export(content []MediaItem, options ExportOptions, w io.Writer) error {
+	paths, err := exportPaths(content, options.Base)
+	if err != nil {
+		return err
+	}
+	playList := wplPlayList{Head: wplHead{
+		Meta: []wplMeta{
+			{Name: "Generator", Content: exportTitle},
+			{Name: "ItemCount", Content: strconv.Itoa(len(content))},
+		},
+		Title: exportTitle,
+	}}
+	for _, path := range paths {
+		playList.Media = append(playList.Media, wplSrc{Src: path})
+	}
+	_, err = io.WriteString(w, wplHeader)
+	if err != nil {
+		return fmt.Errorf("Error writing wpl playlist: %w\n", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+	err = encoder.Encode(playList)
+	if err != nil {
+		return fmt.Errorf("Error writing wpl playlist: %w\n", err)
+	}
+	_, err = io.WriteString(w, "\n")
+	if err != nil {
+		return fmt.Errorf("Error writing wpl playlist: %w\n", err)
+	}
+	return nil
+}
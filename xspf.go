@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UnmarshalXML reads the VLC extension of a track. Elements are matched on
+// their local name, so playlists binding the VLC namespace to another prefix
+// are read too. Extensions of other applications are skipped.
+func (e *Extension) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	application := ""
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "application" {
+			application = attr.Value
+		}
+	}
+	if application != ExtensionApplication {
+		return d.Skip()
+	}
+	ext := Extension{XMLName: start.Name, Application: application}
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			var value string
+			err = d.DecodeElement(&value, &t)
+			if err != nil {
+				return err
+			}
+			switch t.Name.Local {
+			case "id":
+				ext.Id, err = strconv.Atoi(strings.TrimSpace(value))
+				if err != nil {
+					return fmt.Errorf("Invalid vlc:id %q: %w\n", value, err)
+				}
+			case "option":
+				ext.Options = append(ext.Options, strings.TrimSpace(value))
+			}
+		case xml.EndElement:
+			*e = ext
+			return nil
+		}
+	}
+}
+
+// readPlayList parses an XSPF playlist, written by playmix or saved by VLC
+func readPlayList(r io.Reader) (*PlayList, error) {
+	var playList PlayList
+	err := xml.NewDecoder(r).Decode(&playList)
+	if err != nil {
+		return nil, fmt.Errorf("Error in decoding xml: %w\n", err)
+	}
+	return &playList, nil
+}
+
+// loadPlayList reads the playlist file fn and converts its tracks to media items,
+// resolving relative locations against the folder of the file
+func loadPlayList(fn string) (*PlayList, []MediaItem, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Playlist cannot be opened: %w\n", err)
+	}
+	defer f.Close()
+	playList, err := readPlayList(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading %s: %w", fn, err)
+	}
+	base, err := filepath.Abs(filepath.Dir(fn))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot resolve playlist folder: %w\n", err)
+	}
+	items, err := playList.mediaItems(base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading %s: %w", fn, err)
+	}
+	if short := subSecondTracks(items); !playList.writtenByPlaymix() && short > 0 {
+		log.Printf("%d tracks of %s last under a second after reading durations as milliseconds, "+
+			"the playlist may have been written by a playmix version storing seconds\n", short, fn)
+	}
+	return playList, items, nil
+}
+
+// writtenByPlaymix reports whether durations are in seconds, as playmix
+// writes them, instead of the milliseconds of the XSPF specification. Only
+// playlists carrying the seed annotation are recognised; older playmix
+// versions wrote seconds without it.
+func (p *PlayList) writtenByPlaymix() bool {
+	return strings.HasPrefix(p.Annotation, annotationPrefix)
+}
+
+// mediaItems converts the tracks in their playlist order
+func (p *PlayList) mediaItems(base string) ([]MediaItem, error) {
+	var items []MediaItem
+	for _, track := range p.Tl.Tracks {
+		absPath, err := locationPath(track.Location, base)
+		if err != nil {
+			return nil, err
+		}
+		duration := track.Duration
+		if !p.writtenByPlaymix() {
+			duration /= 1000
+		}
+		item := MediaItem{
+			AbsPath:  absPath,
+			Location: track.Location,
+			Name:     track.Title,
+			Id:       track.Ext.Id,
+			Duration: duration,
+			Options:  track.Ext.Options,
+		}
+		if absPath != "" {
+			item.Dir = filepath.Dir(absPath)
+			if item.Name == "" {
+				item.Name = filepath.Base(absPath)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// subSecondTracks counts the tracks with a known duration under a second,
+// a sign of seconds misread as milliseconds
+func subSecondTracks(items []MediaItem) int {
+	short := 0
+	for _, item := range items {
+		if item.Duration > 0 && item.Duration < 1 {
+			short++
+		}
+	}
+	return short
+}
+
+// locationPath decodes a track location to a file path. Relative locations
+// are resolved against base; locations of other schemes have no path.
+func locationPath(location, base string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(location))
+	if err != nil {
+		return "", fmt.Errorf("Invalid location %s: %w\n", location, err)
+	}
+	switch u.Scheme {
+	case "file":
+		return filepath.FromSlash(path.Clean(u.Path)), nil
+	case "":
+		p := filepath.FromSlash(u.Path)
+		if filepath.IsAbs(p) {
+			return filepath.Clean(p), nil
+		}
+		return filepath.Join(base, p), nil
+	default:
+		return "", nil
+	}
+}
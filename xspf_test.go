@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"playmix/internal/assert"
+	"strings"
+	"testing"
+)
+
+func TestReadPlayListRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	err := xspfExporter{}.Export(_createExportItems(), ExportOptions{PlayOptions: PlayOptions{StopTime: 20}, Seed: 42}, &buf)
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+
+	playList, err := readPlayList(&buf)
+	assert.ErrorRaised(t, "Should read written playlist", err, false)
+	assert.Equal(t, "Should detect playmix playlist", playList.writtenByPlaymix(), true)
+	items, err := playList.mediaItems("/tmp")
+	assert.ErrorRaised(t, "Should convert tracks", err, false)
+	assert.Equal(t, "Should read every track", len(items), 2)
+	assert.Equal(t, "Should decode file location", items[0].AbsPath, filepath.FromSlash("/home/Music/rock/a b.mp4"))
+	assert.Equal(t, "Should keep location", items[0].Location, getUrlEncodedPath("/home/Music/rock/a b.mp4"))
+	assert.Equal(t, "Should read title", items[0].Name, "a b.mp4")
+	assert.Equal(t, "Should read seconds", items[0].Duration, 65)
+	assert.Equal(t, "Should read vlc:id", items[1].Id, 1)
+	assert.EqualSlice(t, "Should read vlc:option", items[1].Options, []string{"no-audio", "stop-time=20"})
+}
+
+func TestLoadPlayListSavedByVlc(t *testing.T) {
+	playList, items, err := loadPlayList(filepath.Join("testdata", "vlc.xspf"))
+	assert.ErrorRaised(t, "Should load playlist", err, false)
+	assert.Equal(t, "Should not detect playmix playlist", playList.writtenByPlaymix(), false)
+	assert.Equal(t, "Should read every track", len(items), 3)
+	assert.Equal(t, "Should convert milliseconds", items[0].Duration, 64.6)
+	assert.EqualSlice(t, "Should read options", items[0].Options, []string{"start-time=10"})
+
+	base, _ := filepath.Abs("testdata")
+	assert.Equal(t, "Should resolve relative location", items[1].AbsPath, filepath.Join(base, "jazz", "c.mp4"))
+	assert.Equal(t, "Should name track after file", items[1].Name, "c.mp4")
+	assert.Equal(t, "Should skip other extensions", items[1].Id, 1)
+	assert.Equal(t, "Should have no path for streams", items[2].AbsPath, "")
+	assert.Equal(t, "Should read id of stream", items[2].Id, 2)
+}
+
+func TestReadPlayListErrors(t *testing.T) {
+	_, err := readPlayList(strings.NewReader("<playlist><trackList>"))
+	assert.ErrorRaised(t, "Should raise error for broken xml", err, true)
+
+	bad := `<playlist><trackList><track><extension application="` + ExtensionApplication + `"><vlc:id>x</vlc:id></extension></track></trackList></playlist>`
+	_, err = readPlayList(strings.NewReader(bad))
+	assert.ErrorRaised(t, "Should raise error for invalid id", err, true)
+
+	_, _, err = loadPlayList(filepath.Join("testdata", "missing.xspf"))
+	assert.ErrorRaised(t, "Should raise error for missing file", err, true)
+}
+
+func TestLocationPath(t *testing.T) {
+	p, err := locationPath("file:////home/a%20b.mp4", "/base")
+	assert.ErrorRaised(t, "Should not raise error", err, false)
+	assert.Equal(t, "Should clean playmix file url", p, filepath.FromSlash("/home/a b.mp4"))
+	p, _ = locationPath("/home/c.mp4", "/base")
+	assert.Equal(t, "Should keep absolute path", p, filepath.FromSlash("/home/c.mp4"))
+	_, err = locationPath("file://%zz", "/base")
+	assert.ErrorRaised(t, "Should raise error for invalid url", err, true)
+}
+
+func TestSubSecondTracks(t *testing.T) {
+	legacy := `<playlist><trackList>` +
+		`<track><location>/m/a.mp4</location><duration>65</duration></track>` +
+		`<track><location>/m/b.mp4</location></track>` +
+		`</trackList></playlist>`
+	playList, err := readPlayList(strings.NewReader(legacy))
+	assert.ErrorRaised(t, "Should read playlist", err, false)
+	items, err := playList.mediaItems("/")
+	assert.ErrorRaised(t, "Should convert tracks", err, false)
+	assert.Equal(t, "Should read unannotated durations as milliseconds", items[0].Duration, 0.065)
+	assert.Equal(t, "Should count sub second tracks only", subSecondTracks(items), 1)
+}